@@ -0,0 +1,266 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"time"
+)
+
+// Errors returned by the certificate helpers
+var (
+	ErrInvalidCertificate = errors.New("failed to parse certificate")
+	ErrInvalidCSR         = errors.New("failed to parse certificate request")
+	ErrNotCA              = errors.New("certificate is not a CA")
+)
+
+// certClockSkew backdates certificates so they are valid on hosts with a slightly late clock
+const certClockSkew = 5 * time.Minute
+
+// CertConfig defines the subject, names and lifetime of a certificate
+type CertConfig struct {
+	CommonName   string
+	Organization []string
+	// Hosts are the DNS names and IP addresses of the certificate. They are taken from the CSR if empty when signing a CSR
+	Hosts          []string
+	EmailAddresses []string
+	// Validity is the lifetime of the certificate. It is shortened to the lifetime of the CA
+	Validity time.Duration
+	// ExtKeyUsage defines what a leaf certificate can be used for. Ex: x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth
+	ExtKeyUsage []x509.ExtKeyUsage
+	// MaxPathLen limits the number of intermediate CAs below a CA. Zero allows none
+	MaxPathLen int
+}
+
+// DefaultCAConfig defines the default root CA config
+var DefaultCAConfig = CertConfig{
+	CommonName:   "Swissknife Local CA",
+	Organization: []string{"Swissknife"},
+	Validity:     10 * 365 * 24 * time.Hour,
+}
+
+// DefaultCertConfig defines the default leaf certificate config
+var DefaultCertConfig = CertConfig{
+	Validity:    90 * 24 * time.Hour,
+	ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+}
+
+// CreateRootCA creates a self signed root CA certificate for the key
+func CreateRootCA(key crypto.Signer, config CertConfig) (*x509.Certificate, error) {
+	if config.CommonName == "" {
+		config.CommonName = DefaultCAConfig.CommonName
+	}
+	if len(config.Organization) == 0 {
+		config.Organization = DefaultCAConfig.Organization
+	}
+	if config.Validity <= 0 {
+		config.Validity = DefaultCAConfig.Validity
+	}
+	template, err := newCertTemplate(key.Public(), config)
+	if err != nil {
+		return nil, err
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.MaxPathLen = config.MaxPathLen
+	template.MaxPathLenZero = config.MaxPathLen == 0
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = nil
+	return createCertificate(template, template, key.Public(), key)
+}
+
+// IssueCertificate issues a leaf certificate for the public key signed by the CA
+func IssueCertificate(pub crypto.PublicKey, config CertConfig, caCert *x509.Certificate, caKey crypto.Signer) (*x509.Certificate, error) {
+	if !caCert.IsCA {
+		return nil, ErrNotCA
+	}
+	if config.Validity <= 0 {
+		config.Validity = DefaultCertConfig.Validity
+	}
+	if len(config.ExtKeyUsage) == 0 {
+		config.ExtKeyUsage = DefaultCertConfig.ExtKeyUsage
+	}
+	template, err := newCertTemplate(pub, config)
+	if err != nil {
+		return nil, err
+	}
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	if _, ok := pub.(*rsa.PublicKey); ok {
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	if template.NotAfter.After(caCert.NotAfter) {
+		template.NotAfter = caCert.NotAfter
+	}
+	return createCertificate(template, caCert, pub, caKey)
+}
+
+// CreateCSR creates a DER encoded certificate request signed by the key
+func CreateCSR(key crypto.Signer, config CertConfig) ([]byte, error) {
+	template := &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName:   config.CommonName,
+			Organization: config.Organization,
+		},
+		EmailAddresses: config.EmailAddresses,
+	}
+	template.DNSNames, template.IPAddresses = splitHosts(config.Hosts)
+	return x509.CreateCertificateRequest(rand.Reader, template, key)
+}
+
+// SignCSR issues a leaf certificate for a certificate request signed by the CA. The subject and hosts of the
+// config are used, the ones of the request are only taken if the config has none
+func SignCSR(csr *x509.CertificateRequest, config CertConfig, caCert *x509.Certificate, caKey crypto.Signer) (*x509.Certificate, error) {
+	if err := csr.CheckSignature(); err != nil {
+		return nil, ErrInvalidCSR
+	}
+	if config.CommonName == "" {
+		config.CommonName = csr.Subject.CommonName
+	}
+	if len(config.Organization) == 0 {
+		config.Organization = csr.Subject.Organization
+	}
+	if len(config.Hosts) == 0 {
+		config.Hosts = append(config.Hosts, csr.DNSNames...)
+		for _, ip := range csr.IPAddresses {
+			config.Hosts = append(config.Hosts, ip.String())
+		}
+	}
+	if len(config.EmailAddresses) == 0 {
+		config.EmailAddresses = csr.EmailAddresses
+	}
+	return IssueCertificate(csr.PublicKey, config, caCert, caKey)
+}
+
+// EncodeCertificateToPem encodes the certificate to pem format
+func EncodeCertificateToPem(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+// EncodeCSRToPem encodes a DER certificate request to pem format
+func EncodeCSRToPem(csr []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})
+}
+
+// ExportCertificateChain encodes the certificates to a pem bundle. Pass the leaf first and the root last
+func ExportCertificateChain(certs ...*x509.Certificate) []byte {
+	var bundle []byte
+	for _, cert := range certs {
+		bundle = append(bundle, EncodeCertificateToPem(cert)...)
+	}
+	return bundle
+}
+
+// ParseCertificate parses the first certificate of pem or DER data
+func ParseCertificate(data []byte) (*x509.Certificate, error) {
+	certs, err := ParseCertificateChain(data)
+	if err != nil {
+		return nil, err
+	}
+	return certs[0], nil
+}
+
+// ParseCertificateChain parses all certificates of a pem bundle or a single DER certificate
+func ParseCertificateChain(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		cert, err := x509.ParseCertificate(data)
+		if err != nil {
+			return nil, ErrInvalidCertificate
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// ParseCSR parses a pem or DER certificate request and checks its signature
+func ParseCSR(data []byte) (*x509.CertificateRequest, error) {
+	der := data
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != "CERTIFICATE REQUEST" && block.Type != "NEW CERTIFICATE REQUEST" {
+			return nil, ErrInvalidCSR
+		}
+		der = block.Bytes
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, ErrInvalidCSR
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, ErrInvalidCSR
+	}
+	return csr, nil
+}
+
+// newCertTemplate returns a certificate template with a random serial number
+func newCertTemplate(pub crypto.PublicKey, config CertConfig) (*x509.Certificate, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	subjectKeyID := sha1.Sum(der)
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			CommonName:   config.CommonName,
+			Organization: config.Organization,
+		},
+		NotBefore:      now.Add(-certClockSkew),
+		NotAfter:       now.Add(config.Validity),
+		SubjectKeyId:   subjectKeyID[:],
+		EmailAddresses: config.EmailAddresses,
+		ExtKeyUsage:    config.ExtKeyUsage,
+	}
+	template.DNSNames, template.IPAddresses = splitHosts(config.Hosts)
+	return template, nil
+}
+
+// createCertificate signs the template and parses the result
+func createCertificate(template, parent *x509.Certificate, pub crypto.PublicKey, signer crypto.Signer) (*x509.Certificate, error) {
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, signer)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// splitHosts splits hosts into DNS names and IP addresses
+func splitHosts(hosts []string) (dnsNames []string, ipAddresses []net.IP) {
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			ipAddresses = append(ipAddresses, ip)
+		} else {
+			dnsNames = append(dnsNames, host)
+		}
+	}
+	return dnsNames, ipAddresses
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	gocrypto "crypto"
+	"crypto/elliptic"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/adityak368/swissknife/crypto"
+)
+
+// runCert creates a local CA, certificates and certificate requests
+func runCert(args []string) error {
+	if len(args) == 0 {
+		return &usageError{"cert requires an action: ca, issue, csr or sign"}
+	}
+	action := args[0]
+
+	fs := newFlagSet("cert " + action)
+	cn := fs.String("cn", "", "Common name of the certificate")
+	hosts := fs.String("hosts", "", "Comma separated DNS names and IP addresses of the certificate")
+	client := fs.Bool("client", false, "Issue a client certificate instead of a server certificate")
+	validity := fs.Duration("validity", 0, "Lifetime of the certificate. Defaults to 10 years for a CA and 90 days otherwise")
+	caFile := fs.String("ca", "ca.pem", "CA certificate file")
+	caKeyFile := fs.String("cakey", "ca-key.pem", "CA private key file")
+	keyFile := fs.String("key", "", "Private key file of the certificate request for csr")
+	csrFile := fs.String("csr", "", "Certificate request file for sign")
+	out := fs.String("out", "", "Output name. Writes <out>.pem and <out>-key.pem")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	config := crypto.CertConfig{CommonName: *cn, Validity: *validity}
+	if *hosts != "" {
+		config.Hosts = strings.Split(*hosts, ",")
+	}
+	if *client {
+		config.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+
+	switch action {
+	case "ca":
+		key, err := crypto.GenerateEcdsaKeyPairWithCurve(elliptic.P384())
+		if err != nil {
+			return err
+		}
+		caCert, err := crypto.CreateRootCA(key, config)
+		if err != nil {
+			return err
+		}
+		return writeKeyAndCert(outName(*out, "ca"), key, caCert)
+	case "issue":
+		caCert, caKey, err := readCA(*caFile, *caKeyFile)
+		if err != nil {
+			return err
+		}
+		if config.CommonName == "" && len(config.Hosts) > 0 {
+			config.CommonName = config.Hosts[0]
+		}
+		key, err := crypto.GenerateEcdsaKeyPairWithCurve(elliptic.P256())
+		if err != nil {
+			return err
+		}
+		cert, err := crypto.IssueCertificate(&key.PublicKey, config, caCert, caKey)
+		if err != nil {
+			return err
+		}
+		name := outName(*out, "cert")
+		if err := writeKeyAndCert(name, key, cert); err != nil {
+			return err
+		}
+		return writeOutput(name+"-chain.pem", crypto.ExportCertificateChain(cert, caCert), 0644)
+	case "csr":
+		if err := requireFlag("key", *keyFile); err != nil {
+			return err
+		}
+		privateKey, err := readPrivateKey(*keyFile, nil)
+		if err != nil {
+			return err
+		}
+		signer, ok := privateKey.(gocrypto.Signer)
+		if !ok {
+			return crypto.ErrUnsupportedKeyType
+		}
+		csr, err := crypto.CreateCSR(signer, config)
+		if err != nil {
+			return err
+		}
+		return writeOutput(outFile(*out, ".csr"), crypto.EncodeCSRToPem(csr), 0644)
+	case "sign":
+		if err := requireFlag("csr", *csrFile); err != nil {
+			return err
+		}
+		caCert, caKey, err := readCA(*caFile, *caKeyFile)
+		if err != nil {
+			return err
+		}
+		data, err := readInput(*csrFile)
+		if err != nil {
+			return err
+		}
+		csr, err := crypto.ParseCSR(data)
+		if err != nil {
+			return err
+		}
+		cert, err := crypto.SignCSR(csr, config, caCert, caKey)
+		if err != nil {
+			return err
+		}
+		return writeOutput(outFile(*out, ".pem"), crypto.ExportCertificateChain(cert, caCert), 0644)
+	default:
+		return &usageError{fmt.Sprintf("unknown cert action %q", action)}
+	}
+}
+
+// outName returns the output name or the default name
+func outName(out, defaultName string) string {
+	if out == "" {
+		return defaultName
+	}
+	return out
+}
+
+// outFile returns the output file with the extension, or stdout if no output name is given
+func outFile(out, ext string) string {
+	if out == "" || out == "-" {
+		return "-"
+	}
+	return out + ext
+}
+
+// writeKeyAndCert writes the key to <name>-key.pem and the certificate to <name>.pem
+func writeKeyAndCert(name string, key gocrypto.PrivateKey, cert *x509.Certificate) error {
+	keyPem, err := crypto.MarshalPrivateKey(key, crypto.DefaultKeyExportConfig)
+	if err != nil {
+		return err
+	}
+	if err := writeOutput(name+"-key.pem", keyPem, 0600); err != nil {
+		return err
+	}
+	if err := writeOutput(name+".pem", crypto.EncodeCertificateToPem(cert), 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Created %s.pem and %s-key.pem\n", name, name)
+	return nil
+}
+
+// readCA reads the CA certificate and key
+func readCA(caFile, caKeyFile string) (*x509.Certificate, gocrypto.Signer, error) {
+	data, err := readInput(caFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	caCert, err := crypto.ParseCertificate(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := readPrivateKey(caKeyFile, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	signer, ok := key.(gocrypto.Signer)
+	if !ok {
+		return nil, nil, crypto.ErrUnsupportedKeyType
+	}
+	return caCert, signer, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/adityak368/swissknife/crypto"
+)
+
+// runEncrypt encrypts the input with a secret key as a stream, or with a rsa public key using hybrid encryption
+func runEncrypt(args []string) error {
+	fs := newFlagSet("encrypt")
+	keyFile := fs.String("key", "", "Secret key file")
+	pubFile := fs.String("pub", "", "RSA public key file")
+	alg := fs.String("alg", crypto.DefaultStreamConfig.Algorithm.String(), "AEAD algorithm: XChaCha20-Poly1305, AES-256-GCM or AES-256-GCM-SIV")
+	in := fs.String("in", "-", "Input file, - for stdin")
+	out := fs.String("out", "-", "Output file, - for stdout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	algorithm, err := parseAEADAlgorithm(*alg)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *keyFile != "" && *pubFile == "":
+		key, err := ioutil.ReadFile(*keyFile)
+		if err != nil {
+			return err
+		}
+		config := crypto.DefaultStreamConfig
+		config.Algorithm = algorithm
+		return transform(*in, *out, 0644, func(dst io.Writer, src io.Reader) error {
+			return crypto.EncryptStreamWithConfig(dst, src, key, config)
+		})
+	case *pubFile != "" && *keyFile == "":
+		publicKey, err := readPublicKey(*pubFile)
+		if err != nil {
+			return err
+		}
+		rsaKey, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return &usageError{"-pub must be a rsa public key"}
+		}
+		msg, err := readInput(*in)
+		if err != nil {
+			return err
+		}
+		ciphertext, err := crypto.EncryptRsa(msg, rsaKey, crypto.RsaOptions{Encryption: crypto.RsaHybrid, Algorithm: algorithm})
+		if err != nil {
+			return err
+		}
+		return writeOutput(*out, ciphertext, 0644)
+	default:
+		return &usageError{"exactly one of -key or -pub is required"}
+	}
+}
+
+// runDecrypt decrypts the output of runEncrypt
+func runDecrypt(args []string) error {
+	fs := newFlagSet("decrypt")
+	keyFile := fs.String("key", "", "Secret key file")
+	privFile := fs.String("priv", "", "RSA private key file")
+	in := fs.String("in", "-", "Input file, - for stdin")
+	out := fs.String("out", "-", "Output file, - for stdout")
+	passphrase := passphraseFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	switch {
+	case *keyFile != "" && *privFile == "":
+		key, err := ioutil.ReadFile(*keyFile)
+		if err != nil {
+			return err
+		}
+		// The plaintext is only readable by the owner
+		return transform(*in, *out, 0600, func(dst io.Writer, src io.Reader) error {
+			return crypto.DecryptStream(dst, src, key)
+		})
+	case *privFile != "" && *keyFile == "":
+		pass, err := passphrase()
+		if err != nil {
+			return err
+		}
+		privateKey, err := readPrivateKey(*privFile, pass)
+		if err != nil {
+			return err
+		}
+		rsaKey, ok := privateKey.(*rsa.PrivateKey)
+		if !ok {
+			return &usageError{"-priv must be a rsa private key"}
+		}
+		ciphertext, err := readInput(*in)
+		if err != nil {
+			return err
+		}
+		msg, err := crypto.DecryptRsa(ciphertext, rsaKey, crypto.RsaOptions{Encryption: crypto.RsaHybrid})
+		if err != nil {
+			return err
+		}
+		return writeOutput(*out, msg, 0600)
+	default:
+		return &usageError{"exactly one of -key or -priv is required"}
+	}
+}
+
+// transform streams the input through the function into the output. The output file is only written if the function succeeds
+func transform(in, out string, perm os.FileMode, fn func(dst io.Writer, src io.Reader) error) error {
+	src, err := openInput(in)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := createOutput(out, perm)
+	if err != nil {
+		return err
+	}
+	if err := fn(dst, src); err != nil {
+		dst.Abort()
+		return err
+	}
+	return dst.Close()
+}
+
+// parseAEADAlgorithm parses the name of an aead algorithm
+func parseAEADAlgorithm(name string) (crypto.AEADAlgorithm, error) {
+	for _, alg := range []crypto.AEADAlgorithm{crypto.XChaCha20Poly1305, crypto.AES256GCM, crypto.AES256GCMSIV} {
+		if strings.EqualFold(alg.String(), name) {
+			return alg, nil
+		}
+	}
+	return 0, &usageError{fmt.Sprintf("unknown algorithm %q", name)}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// runHash prints the hex hash of the input
+func runHash(args []string) error {
+	fs := newFlagSet("hash")
+	alg := fs.String("alg", "sha512", "Hash algorithm: sha256, sha384, sha512 or blake2b")
+	in := fs.String("in", "-", "Input file, - for stdin")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	hashFunc, err := parseHash(*alg)
+	if err != nil {
+		return err
+	}
+	return printDigest(*in, hashFunc())
+}
+
+// runHMAC prints the hex hmac of the input
+func runHMAC(args []string) error {
+	fs := newFlagSet("hmac")
+	keyFile := fs.String("key", "", "Secret key file")
+	alg := fs.String("alg", "sha512", "Hash algorithm: sha256, sha384, sha512 or blake2b")
+	in := fs.String("in", "-", "Input file, - for stdin")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := requireFlag("key", *keyFile); err != nil {
+		return err
+	}
+	hashFunc, err := parseHash(*alg)
+	if err != nil {
+		return err
+	}
+	key, err := ioutil.ReadFile(*keyFile)
+	if err != nil {
+		return err
+	}
+	return printDigest(*in, hmac.New(hashFunc, key))
+}
+
+// printDigest hashes the input and prints the hex digest
+func printDigest(in string, h hash.Hash) error {
+	src, err := openInput(in)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if _, err := io.Copy(h, src); err != nil {
+		return err
+	}
+	fmt.Println(hex.EncodeToString(h.Sum(nil)))
+	return nil
+}
+
+// parseHash parses the name of a hash algorithm
+func parseHash(name string) (func() hash.Hash, error) {
+	switch strings.ToLower(name) {
+	case "sha256":
+		return sha256.New, nil
+	case "sha384":
+		return sha512.New384, nil
+	case "sha512":
+		return sha512.New, nil
+	case "blake2b":
+		return func() hash.Hash {
+			h, _ := blake2b.New512(nil)
+			return h
+		}, nil
+	default:
+		return nil, &usageError{fmt.Sprintf("unknown hash algorithm %q", name)}
+	}
+}
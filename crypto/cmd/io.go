@@ -0,0 +1,177 @@
+package main
+
+import (
+	gocrypto "crypto"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adityak368/swissknife/crypto"
+)
+
+// newFlagSet returns a flag set that returns errors instead of exiting
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+// parseFlags parses the arguments and converts parse errors to usage errors
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return &usageError{err.Error()}
+	}
+	if fs.NArg() > 0 {
+		return &usageError{fmt.Sprintf("unexpected arguments %v", fs.Args())}
+	}
+	return nil
+}
+
+// requireFlag returns a usage error if the flag value is empty
+func requireFlag(name, value string) error {
+	if value == "" {
+		return &usageError{"flag -" + name + " is required"}
+	}
+	return nil
+}
+
+// openInput opens the file or stdin for -
+func openInput(fileName string) (io.ReadCloser, error) {
+	if fileName == "-" || fileName == "" {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+	return os.Open(fileName)
+}
+
+// readInput reads the whole file or stdin for -
+func readInput(fileName string) ([]byte, error) {
+	in, err := openInput(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	return ioutil.ReadAll(in)
+}
+
+// output is where a command writes its result. Close commits the output, Abort discards it
+type output interface {
+	io.WriteCloser
+	Abort()
+}
+
+// nopWriteCloser does not close stdout
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close implements the io.Closer interface
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// Abort implements the output interface. What was written to stdout can not be taken back
+func (nopWriteCloser) Abort() {}
+
+// outputFile is a temporary file in the folder of the output file. It replaces the output file when closed,
+// so a failed command never leaves a partial output behind
+type outputFile struct {
+	*os.File
+	fileName string
+	perm     os.FileMode
+}
+
+// Close implements the io.Closer interface
+func (f *outputFile) Close() error {
+	err := f.File.Chmod(f.perm)
+	if closeErr := f.File.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.File.Name(), f.fileName)
+	}
+	if err != nil {
+		os.Remove(f.File.Name())
+	}
+	return err
+}
+
+// Abort implements the output interface
+func (f *outputFile) Abort() {
+	f.File.Close()
+	os.Remove(f.File.Name())
+}
+
+// createOutput creates the file with the permissions or returns stdout for -
+func createOutput(fileName string, perm os.FileMode) (output, error) {
+	if fileName == "-" || fileName == "" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	// The temporary file is only readable by the owner until it gets its permissions
+	file, err := ioutil.TempFile(filepath.Dir(fileName), "."+filepath.Base(fileName)+".tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &outputFile{File: file, fileName: fileName, perm: perm}, nil
+}
+
+// writeOutput writes the data to the file or stdout for -
+func writeOutput(fileName string, data []byte, perm os.FileMode) error {
+	out, err := createOutput(fileName, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(data); err != nil {
+		out.Abort()
+		return err
+	}
+	return out.Close()
+}
+
+// passphraseFlags registers the flags to read a passphrase from an environment variable or a file
+func passphraseFlags(fs *flag.FlagSet) func() ([]byte, error) {
+	env := fs.String("passphrase-env", "", "Name of the environment variable holding the passphrase")
+	file := fs.String("passphrase-file", "", "File holding the passphrase")
+	return func() ([]byte, error) {
+		switch {
+		case *env != "":
+			value, ok := os.LookupEnv(*env)
+			if !ok {
+				return nil, fmt.Errorf("environment variable %s is not set", *env)
+			}
+			return []byte(value), nil
+		case *file != "":
+			data, err := ioutil.ReadFile(*file)
+			if err != nil {
+				return nil, err
+			}
+			return []byte(strings.TrimRight(string(data), "\r\n")), nil
+		default:
+			return nil, nil
+		}
+	}
+}
+
+// readPrivateKey reads a private key in any supported format
+func readPrivateKey(fileName string, passphrase []byte) (gocrypto.PrivateKey, error) {
+	data, err := readInput(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.ParsePrivateKey(data, passphrase)
+}
+
+// readPublicKey reads a public key in any supported format
+func readPublicKey(fileName string) (gocrypto.PublicKey, error) {
+	data, err := readInput(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.ParsePublicKey(data)
+}
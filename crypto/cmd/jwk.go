@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/adityak368/swissknife/crypto"
+	"github.com/adityak368/swissknife/crypto/token"
+)
+
+// runJWK prints a public key as JWK or the public keys of the named signing keys of a keystore as JWKS
+func runJWK(args []string) error {
+	fs := newFlagSet("jwk")
+	pubFile := fs.String("pub", "", "Public key file")
+	keyID := fs.String("kid", "", "Key id of the JWK")
+	keystoreFile := fs.String("keystore", "", "Keystore file")
+	keyNames := fs.String("keys", "", "Comma separated names of the signing keys to export from the keystore")
+	passphrase := passphraseFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var value interface{}
+	switch {
+	case *pubFile != "" && *keystoreFile == "":
+		publicKey, err := readPublicKey(*pubFile)
+		if err != nil {
+			return err
+		}
+		if value, err = token.NewJWK(publicKey, *keyID); err != nil {
+			return err
+		}
+	case *keystoreFile != "" && *pubFile == "":
+		if err := requireFlag("keys", *keyNames); err != nil {
+			return err
+		}
+		pass, err := passphrase()
+		if err != nil {
+			return err
+		}
+		store, err := crypto.LoadKeyStoreFromFile(*keystoreFile, pass)
+		if err != nil {
+			return err
+		}
+		if value, err = token.NewJWKS(store, strings.Split(*keyNames, ",")...); err != nil {
+			return err
+		}
+	default:
+		return &usageError{"exactly one of -pub or -keystore is required"}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
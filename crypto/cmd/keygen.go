@@ -0,0 +1,136 @@
+package main
+
+import (
+	gocrypto "crypto"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/adityak368/swissknife/crypto"
+)
+
+// runKeygen generates a key pair or a secret key
+func runKeygen(args []string) error {
+	fs := newFlagSet("keygen")
+	keyType := fs.String("type", "rsa", "Key type: rsa, ecdsa, ed25519 or secret")
+	bits := fs.Int("bits", 4096, "RSA key size in bits")
+	curve := fs.String("curve", "P-256", "ECDSA curve: P-256, P-384 or P-521")
+	size := fs.Int("size", 32, "Secret key size in bytes")
+	out := fs.String("out", "privatekey.pem", "Private key file, - for stdout")
+	pubOut := fs.String("pubout", "pubkey.pub", "Public key file, empty to skip")
+	format := fs.String("format", "pkcs8", "Private key format: pkcs8, pkcs1 (rsa) or sec1 (ecdsa)")
+	pubFormat := fs.String("pubformat", "pkix", "Public key format: pkix, pkcs1 (rsa) or openssh")
+	passphrase := passphraseFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *keyType == "secret" {
+		if *size < 16 {
+			return &usageError{"secret keys must be at least 16 bytes"}
+		}
+		secret := make([]byte, *size)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+		return writeOutput(*out, secret, 0600)
+	}
+
+	var privateKey gocrypto.PrivateKey
+	var publicKey gocrypto.PublicKey
+	switch *keyType {
+	case "rsa":
+		key, err := crypto.GenerateRsaKeyPair(*bits)
+		if err != nil {
+			return err
+		}
+		privateKey, publicKey = key, &key.PublicKey
+	case "ecdsa":
+		c, err := parseCurve(*curve)
+		if err != nil {
+			return err
+		}
+		key, err := crypto.GenerateEcdsaKeyPairWithCurve(c)
+		if err != nil {
+			return err
+		}
+		privateKey, publicKey = key, &key.PublicKey
+	case "ed25519":
+		pub, priv, err := crypto.GenerateEd25519KeyPair()
+		if err != nil {
+			return err
+		}
+		privateKey, publicKey = priv, pub
+	default:
+		return &usageError{fmt.Sprintf("unknown key type %q", *keyType)}
+	}
+
+	config := crypto.DefaultKeyExportConfig
+	var err error
+	if config.Format, err = parseKeyFormat(*format); err != nil {
+		return err
+	}
+	if config.Passphrase, err = passphrase(); err != nil {
+		return err
+	}
+	privatePem, err := crypto.MarshalPrivateKey(privateKey, config)
+	if err != nil {
+		return err
+	}
+	if err := writeOutput(*out, privatePem, 0600); err != nil {
+		return err
+	}
+
+	if *pubOut == "" {
+		return nil
+	}
+	pubConfig := crypto.DefaultKeyExportConfig
+	if pubConfig.Format, err = parseKeyFormat(*pubFormat); err != nil {
+		return err
+	}
+	publicPem, err := crypto.MarshalPublicKey(publicKey, pubConfig)
+	if err != nil {
+		return err
+	}
+	if err := writeOutput(*pubOut, publicPem, 0644); err != nil {
+		return err
+	}
+	if *out != "-" && *pubOut != "-" {
+		fmt.Fprintf(os.Stderr, "Generated %s key pair %s and %s\n", *keyType, *out, *pubOut)
+	}
+	return nil
+}
+
+// parseKeyFormat parses the name of a key format
+func parseKeyFormat(name string) (crypto.KeyFormat, error) {
+	switch strings.ToLower(name) {
+	case "pkcs1":
+		return crypto.KeyFormatPkcs1, nil
+	case "sec1":
+		return crypto.KeyFormatSec1, nil
+	case "pkcs8":
+		return crypto.KeyFormatPkcs8, nil
+	case "pkix":
+		return crypto.KeyFormatPkix, nil
+	case "openssh":
+		return crypto.KeyFormatOpenSSH, nil
+	default:
+		return 0, &usageError{fmt.Sprintf("unknown key format %q", name)}
+	}
+}
+
+// parseCurve parses the name of an ecdsa curve
+func parseCurve(name string) (elliptic.Curve, error) {
+	switch strings.ToUpper(name) {
+	case "P-256", "P256":
+		return elliptic.P256(), nil
+	case "P-384", "P384":
+		return elliptic.P384(), nil
+	case "P-521", "P521":
+		return elliptic.P521(), nil
+	default:
+		return nil, &usageError{fmt.Sprintf("unknown curve %q", name)}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/adityak368/swissknife/crypto"
+)
+
+// runKeystore manages a passphrase encrypted keystore file
+func runKeystore(args []string) error {
+	if len(args) == 0 {
+		return &usageError{"keystore requires an action: list, add, rotate, remove or state"}
+	}
+	action := args[0]
+
+	fs := newFlagSet("keystore " + action)
+	file := fs.String("file", "keystore.sks", "Keystore file")
+	name := fs.String("name", "", "Key name")
+	keyFile := fs.String("key", "", "Private key file to add or rotate")
+	secretFile := fs.String("secret", "", "Secret key file to add or rotate")
+	keyID := fs.String("id", "", "Key version id for state")
+	state := fs.String("state", "", "Key state for state: active, decrypt-only or retired")
+	passphrase := passphraseFlags(fs)
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+	pass, err := passphrase()
+	if err != nil {
+		return err
+	}
+	if len(pass) == 0 {
+		return &usageError{"a passphrase is required, use -passphrase-env or -passphrase-file"}
+	}
+
+	store, err := crypto.LoadKeyStoreFromFile(*file, pass)
+	if os.IsNotExist(err) && action == "add" {
+		store, err = crypto.NewKeyStore(), nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "list":
+		return listKeys(store)
+	case "add", "rotate":
+		if err := requireFlag("name", *name); err != nil {
+			return err
+		}
+		if err := addKey(store, *name, *keyFile, *secretFile, action == "rotate"); err != nil {
+			return err
+		}
+	case "remove":
+		if err := requireFlag("name", *name); err != nil {
+			return err
+		}
+		if !store.RemoveKey(*name) {
+			return crypto.ErrKeyNotFound
+		}
+	case "state":
+		if err := requireFlag("name", *name); err != nil {
+			return err
+		}
+		if err := requireFlag("id", *keyID); err != nil {
+			return err
+		}
+		keyState, err := crypto.ParseKeyState(*state)
+		if err != nil {
+			return &usageError{err.Error()}
+		}
+		if err := store.SetKeyState(*name, *keyID, keyState); err != nil {
+			return err
+		}
+	default:
+		return &usageError{fmt.Sprintf("unknown keystore action %q", action)}
+	}
+	return store.SaveToFile(*file, pass)
+}
+
+// addKey adds or rotates the private or secret key in the keystore
+func addKey(store *crypto.KeyStore, name, keyFile, secretFile string, rotate bool) error {
+	var privateKey interface{}
+	switch {
+	case keyFile != "" && secretFile == "":
+		key, err := readPrivateKey(keyFile, nil)
+		if err != nil {
+			return err
+		}
+		privateKey = key
+	case secretFile != "" && keyFile == "":
+		secret, err := ioutil.ReadFile(secretFile)
+		if err != nil {
+			return err
+		}
+		privateKey = secret
+	default:
+		return &usageError{"exactly one of -key or -secret is required"}
+	}
+
+	if rotate {
+		return store.RotatePrivateKey(name, privateKey)
+	}
+	if secret, ok := privateKey.([]byte); ok {
+		return store.AddSecretKey(name, secret)
+	}
+	return store.AddPrivateKey(name, privateKey)
+}
+
+// listKeys prints the versions of all keys
+func listKeys(store *crypto.KeyStore) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tID\tTYPE\tSTATE\tCREATED")
+	for _, name := range store.KeyNames() {
+		for _, version := range store.KeyVersions(name) {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, version.ID, version.Key.Type(), version.State, version.CreatedAt.Format(time.RFC3339))
+		}
+	}
+	return w.Flush()
+}
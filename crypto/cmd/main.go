@@ -1,37 +1,99 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
-
-	"github.com/adityak368/swissknife/crypto"
+	"os"
 )
 
-// A CLI to generate a pub/priv rsa key pair
+// A CLI for keys, signatures, encryption, hashes, keystores, certificates and secret sharing.
+// Inputs and outputs default to stdin and stdout so commands can be piped
 
-func main() {
+// command defines a subcommand of the CLI
+type command struct {
+	name        string
+	usage       string
+	description string
+	run         func(args []string) error
+}
 
-	generatersa := flag.Bool("generatersa", false, "Generate RSA Pub/Priv Key Pair")
-	flag.Parse()
+var commands = []command{
+	{"keygen", "keygen -type rsa|ecdsa|ed25519|secret [-bits 4096] [-curve P-256] [-out privatekey.pem] [-pubout pubkey.pub]", "Generate a key pair or a secret key", runKeygen},
+	{"sign", "sign -key privatekey.pem [-in file] [-out file] [-base64]", "Sign a file", runSign},
+	{"verify", "verify -pub pubkey.pub -sig file [-in file] [-base64]", "Verify the signature of a file", runVerify},
+	{"encrypt", "encrypt -key secretfile | -pub pubkey.pub [-alg XChaCha20-Poly1305] [-in file] [-out file]", "Encrypt a file or stream", runEncrypt},
+	{"decrypt", "decrypt -key secretfile | -priv privatekey.pem [-in file] [-out file]", "Decrypt a file or stream", runDecrypt},
+	{"hash", "hash [-alg sha512] [-in file]", "Print the hex hash of a file", runHash},
+	{"hmac", "hmac -key secretfile [-alg sha512] [-in file]", "Print the hex hmac of a file", runHMAC},
+	{"jwk", "jwk -pub pubkey.pub [-kid id] | -keystore keystore.sks -keys signing", "Print a public key as JWK or the public keys of the named signing keys of a keystore as JWKS", runJWK},
+	{"keystore", "keystore list|add|rotate|remove|state -file keystore.sks [-name name] [-key privatekey.pem] [-secret file] [-id keyid] [-state active]", "Manage a passphrase encrypted keystore file", runKeystore},
+	{"cert", "cert ca|issue|csr|sign [-cn name] [-hosts a,b] [-client] [-ca ca.pem] [-cakey ca-key.pem]", "Create a local CA, certificates and certificate requests", runCert},
+	{"split", "split -n 5 -k 3 [-in secretfile] [-out share]", "Split a secret into n shares of which k recover it", runSplit},
+	{"combine", "combine [-in shares] [-out secretfile]", "Recover a secret from shares, one per line", runCombine},
+}
 
-	if *generatersa {
-		// Create the keys
-		priv, err := crypto.GenerateRsaKeyPair(4096)
-		if err != nil {
-			panic(err)
-		}
+// usageError is returned for invalid arguments
+type usageError struct {
+	message string
+}
 
-		if err := crypto.ExportRsaPrivateKeyToFile("privatekey.pem", priv); err != nil {
-			panic(err)
-		}
+// Error implements the error interface
+func (e *usageError) Error() string {
+	return e.message
+}
+
+// errVerificationFailed is returned when a signature is invalid
+var errVerificationFailed = errors.New("verification failed")
 
-		if err := crypto.ExportRsaPublicKeyToFile("pubkey.pub", &priv.PublicKey); err != nil {
-			panic(err)
+func main() {
+	// Compatibility with the flag only CLI
+	if len(os.Args) > 1 && (os.Args[1] == "-generatersa" || os.Args[1] == "--generatersa") {
+		os.Exit(exitCode(runKeygen([]string{"-type", "rsa", "-bits", "4096", "-format", "pkcs1", "-pubformat", "pkcs1"})))
+	}
+
+	if len(os.Args) < 2 || os.Args[1] == "help" || os.Args[1] == "-h" || os.Args[1] == "--help" {
+		printUsage()
+		if len(os.Args) < 2 {
+			os.Exit(2)
 		}
-		fmt.Println("Generated Pub/Priv Key Pair...")
 		return
 	}
 
-	fmt.Println("No options given. Doing nothing...")
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			os.Exit(exitCode(cmd.run(os.Args[2:])))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+	printUsage()
+	os.Exit(2)
+}
+
+// exitCode prints the error and returns the exit code for it
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	fmt.Fprintln(os.Stderr, "error:", err)
+	var usage *usageError
+	if errors.As(err, &usage) {
+		return 2
+	}
+	return 1
+}
 
+// printUsage prints the commands of the CLI
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: crypto <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.description)
+		fmt.Fprintf(os.Stderr, "             %s\n", cmd.usage)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Use - as file name for stdin or stdout. Run crypto <command> -h for the flags of a command")
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/adityak368/swissknife/crypto"
+)
+
+// runSplit splits a secret into base64 encoded shares
+func runSplit(args []string) error {
+	fs := newFlagSet("split")
+	n := fs.Int("n", 5, "Number of shares")
+	threshold := fs.Int("k", 3, "Number of shares required to recover the secret")
+	in := fs.String("in", "-", "Secret file, - for stdin")
+	out := fs.String("out", "", "Write share i to <out>.<i> instead of one share per line to stdout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *threshold < 2 || *threshold > *n || *n > 255 {
+		return &usageError{crypto.ErrInvalidThreshold.Error()}
+	}
+
+	secret, err := readInput(*in)
+	if err != nil {
+		return err
+	}
+	shares, err := crypto.SplitSecret(secret, *n, *threshold)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		var buf bytes.Buffer
+		for _, share := range shares {
+			buf.WriteString(base64.StdEncoding.EncodeToString(share) + "\n")
+		}
+		return writeOutput("-", buf.Bytes(), 0600)
+	}
+	for i, share := range shares {
+		if err := writeOutput(fmt.Sprintf("%s.%d", *out, i+1), []byte(base64.StdEncoding.EncodeToString(share)+"\n"), 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runCombine recovers a secret from base64 encoded shares, one per line
+func runCombine(args []string) error {
+	fs := newFlagSet("combine")
+	in := fs.String("in", "-", "File with one share per line, - for stdin")
+	out := fs.String("out", "-", "Secret file, - for stdout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	data, err := readInput(*in)
+	if err != nil {
+		return err
+	}
+	var shares [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		share, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return crypto.ErrInvalidShare
+		}
+		shares = append(shares, share)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	secret, err := crypto.CombineShares(shares)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*out, secret, 0600)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/adityak368/swissknife/crypto"
+)
+
+// runSign signs the input with a private key
+func runSign(args []string) error {
+	fs := newFlagSet("sign")
+	keyFile := fs.String("key", "", "Private key file")
+	in := fs.String("in", "-", "Input file, - for stdin")
+	out := fs.String("out", "-", "Signature file, - for stdout")
+	encode := fs.Bool("base64", false, "Write the signature base64 encoded")
+	passphrase := passphraseFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := requireFlag("key", *keyFile); err != nil {
+		return err
+	}
+
+	pass, err := passphrase()
+	if err != nil {
+		return err
+	}
+	privateKey, err := readPrivateKey(*keyFile, pass)
+	if err != nil {
+		return err
+	}
+	key, err := crypto.NewKey(privateKey)
+	if err != nil {
+		return err
+	}
+	msg, err := readInput(*in)
+	if err != nil {
+		return err
+	}
+	signature, err := key.Sign(msg)
+	if err != nil {
+		return err
+	}
+	if *encode {
+		signature = []byte(base64.StdEncoding.EncodeToString(signature) + "\n")
+	}
+	return writeOutput(*out, signature, 0644)
+}
+
+// runVerify verifies the signature of the input with a public key. It fails if the signature is invalid
+func runVerify(args []string) error {
+	fs := newFlagSet("verify")
+	pubFile := fs.String("pub", "", "Public key file")
+	sigFile := fs.String("sig", "", "Signature file")
+	in := fs.String("in", "-", "Input file, - for stdin")
+	encoded := fs.Bool("base64", false, "The signature is base64 encoded")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := requireFlag("pub", *pubFile); err != nil {
+		return err
+	}
+	if err := requireFlag("sig", *sigFile); err != nil {
+		return err
+	}
+	if *sigFile == "-" && (*in == "-" || *in == "") {
+		return &usageError{"the signature and the input can not both be read from stdin"}
+	}
+
+	publicKey, err := readPublicKey(*pubFile)
+	if err != nil {
+		return err
+	}
+	signature, err := readInput(*sigFile)
+	if err != nil {
+		return err
+	}
+	if *encoded {
+		if signature, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature))); err != nil {
+			return err
+		}
+	}
+	msg, err := readInput(*in)
+	if err != nil {
+		return err
+	}
+	if err := crypto.VerifyWithPublicKey(publicKey, msg, signature); err != nil {
+		if err == crypto.ErrVerification {
+			return errVerificationFailed
+		}
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Verified OK")
+	return nil
+}
@@ -1,7 +1,6 @@
 package crypto
 
 import (
-	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/hmac"
@@ -13,8 +12,6 @@ import (
 	"errors"
 	"hash"
 	"io/ioutil"
-
-	"golang.org/x/crypto/chacha20poly1305"
 )
 
 // GenerateRsaKeyPair generates a pub/priv rsa key pair
@@ -57,7 +54,7 @@ func ExportRsaPrivateKeyToFile(fileName string, key *rsa.PrivateKey) error {
 	priv_pem := ExportRsaPrivateKeyAsPemStr(key)
 
 	// Write to file
-	return ioutil.WriteFile(fileName, []byte(priv_pem), 0644)
+	return ioutil.WriteFile(fileName, []byte(priv_pem), 0600)
 }
 
 // ExportRsaPublicKeyToFile exports the rsa pub key to a file
@@ -70,29 +67,28 @@ func ExportRsaPublicKeyToFile(fileName string, key *rsa.PublicKey) error {
 	return ioutil.WriteFile(fileName, []byte(pubKey), 0644)
 }
 
-// ParseRsaPrivateKeyFromPemStr parses the private key from the given pem string
+// ParseRsaPrivateKeyFromPemStr parses the private key from the given pem string. PKCS#1 and PKCS#8 are accepted
 func ParseRsaPrivateKeyFromPemStr(privPEM string) (*rsa.PrivateKey, error) {
-	block, _ := pem.Decode([]byte(privPEM))
-	if block == nil {
-		return nil, errors.New("failed to parse PEM block containing the key")
-	}
-
-	priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	priv, err := ParsePrivateKey([]byte(privPEM), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	return priv, nil
+	rsaKey, ok := priv.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("Key type is not RSA")
+	}
+	return rsaKey, nil
 }
 
-// ParseRsaPublicKeyFromPemStr parses the pub key from the given pem string
+// ParseRsaPublicKeyFromPemStr parses the pub key from the given pem string. PKIX and PKCS#1 are accepted,
+// so keys exported by ExportRsaPublicKeyAsPemStr can be parsed
 func ParseRsaPublicKeyFromPemStr(pubPEM string) (*rsa.PublicKey, error) {
-	block, _ := pem.Decode([]byte(pubPEM))
-	if block == nil {
+	if block, _ := pem.Decode([]byte(pubPEM)); block == nil {
 		return nil, errors.New("failed to parse PEM block containing the key")
 	}
 
-	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	pub, err := ParsePublicKey([]byte(pubPEM))
 	if err != nil {
 		return nil, err
 	}
@@ -119,76 +115,22 @@ func ParseRsaPrivateKeyFromFile(fileName string) (*rsa.PrivateKey, error) {
 	return ParseRsaPrivateKeyFromPemStr(string(priv_pem))
 }
 
-// GetHash returns the hash of a message
+// GetHash returns the hash of a message. Do not use it for passwords, use the password package
 func GetHash(msg []byte) []byte {
 	h := sha512.New()
 	h.Write(msg)
 	return h.Sum(nil)
 }
 
-// Sign returns a signature made by combining the message and the signers private key
+// Sign returns a signature made by combining the message and the signers private key.
+// It uses PKCS#1 v1.5 with SHA-512, use SignRsa for other paddings and hashes
 func Sign(msg []byte, key *rsa.PrivateKey) (signature []byte, err error) {
-	hs := GetHash(msg)
-	return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA512, hs)
+	return SignRsa(msg, key, pkcs1v15Sha512Options)
 }
 
 // Verify checks if a message is signed by a given Public Key
 func Verify(msg []byte, sig []byte, pk *rsa.PublicKey) error {
-	hs := GetHash(msg)
-	return rsa.VerifyPKCS1v15(pk, crypto.SHA512, hs, sig)
-}
-
-// EncryptUsingSymmKey Enrypts data using ChaCha2020
-// TODO: Give option to change encryption algo
-func EncryptUsingSymmKey(msg, privKey []byte) ([]byte, error) {
-	hs := GetHash(privKey)
-	key := make([]byte, chacha20poly1305.KeySize)
-	if _, err := rand.Read(hs); err != nil {
-		return nil, err
-	}
-
-	aead, err := chacha20poly1305.NewX(key)
-	if err != nil {
-		return nil, err
-	}
-	var encryptedMsg []byte
-	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(msg)+aead.Overhead())
-	if _, err := rand.Read(nonce); err != nil {
-		return nil, err
-	}
-	// Encrypt the message and append the ciphertext to the nonce.
-	encryptedMsg = aead.Seal(nonce, nonce, msg, nil)
-
-	return encryptedMsg, nil
-}
-
-// DecryptUsingSymmKey Decrypts data using ChaCha2020
-// TODO: Give option to change encryption algo
-func DecryptUsingSymmKey(encryptedMsg, privKey []byte) ([]byte, error) {
-	hs := GetHash(privKey)
-	key := make([]byte, chacha20poly1305.KeySize)
-	if _, err := rand.Read(hs); err != nil {
-		return nil, err
-	}
-
-	aead, err := chacha20poly1305.NewX(key)
-	if err != nil {
-		return nil, err
-	}
-	if len(encryptedMsg) < aead.NonceSize() {
-		return nil, errors.New("ciphertext too short")
-	}
-
-	// Split nonce and ciphertext.
-	nonce, ciphertext := encryptedMsg[:aead.NonceSize()], encryptedMsg[aead.NonceSize():]
-
-	// Decrypt the message and check it wasn't tampered with.
-	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	return plaintext, nil
+	return VerifyRsa(msg, sig, pk, pkcs1v15Sha512Options)
 }
 
 // HMAC returns the hmac of the message and key
@@ -221,6 +163,11 @@ func GenerateEcdsaKeyPair() (*ecdsa.PrivateKey, error) {
 	return ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
 }
 
+// GenerateEcdsaKeyPairWithCurve generates a pub/priv ecdsa key pair on the given curve. Ex: elliptic.P256() for TLS
+func GenerateEcdsaKeyPairWithCurve(curve elliptic.Curve) (*ecdsa.PrivateKey, error) {
+	return ecdsa.GenerateKey(curve, rand.Reader)
+}
+
 // EncodeEcdsaPrivateKeyToPem encodes ecdsa private key to pem format
 func EncodeEcdsaPrivateKeyToPem(privateKey *ecdsa.PrivateKey) ([]byte, []byte, error) {
 	x509EncodedPriv, err := x509.MarshalECPrivateKey(privateKey)
@@ -238,13 +185,18 @@ func EncodeEcdsaPrivateKeyToPem(privateKey *ecdsa.PrivateKey) ([]byte, []byte, e
 	return pemEncodedPriv, pemEncodedPub, nil
 }
 
-// DecodeEcdsaPrivateKeyFromPem decodes pem private key
+// DecodeEcdsaPrivateKeyFromPem decodes pem private key. SEC 1 and PKCS#8 are accepted
 func DecodeEcdsaPrivateKeyFromPem(pemEncodedPrivKey []byte) (*ecdsa.PrivateKey, error) {
-	block, _ := pem.Decode(pemEncodedPrivKey)
-	x509Encoded := block.Bytes
-	privateKey, err := x509.ParseECPrivateKey(x509Encoded)
+	if block, _ := pem.Decode(pemEncodedPrivKey); block == nil {
+		return nil, errors.New("failed to parse PEM block containing the key")
+	}
+	privateKey, err := ParsePrivateKey(pemEncodedPrivKey, nil)
 	if err != nil {
 		return nil, err
 	}
-	return privateKey, nil
+	ecdsaKey, ok := privateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("Key type is not ECDSA")
+	}
+	return ecdsaKey, nil
 }
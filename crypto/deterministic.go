@@ -0,0 +1,59 @@
+package crypto
+
+import "crypto/cipher"
+
+// deterministicVersion is the current version of the deterministic ciphertext format
+const deterministicVersion byte = 1
+
+// EncryptDeterministic Encrypts data so that the same message, key and associated data always give the same ciphertext,
+// which allows equality lookups on encrypted values. It uses AES-256-GCM-SIV, which stays secure with a fixed nonce
+// and only reveals whether two messages are equal. Use EncryptUsingSymmKey unless lookups are needed.
+// The output is: version | algorithm | ciphertext
+func EncryptDeterministic(msg, privKey, associatedData []byte) ([]byte, error) {
+	if len(privKey) == 0 {
+		return nil, ErrEmptyKey
+	}
+	aead, err := newDeterministicAEAD(privKey)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 2, 2+len(msg)+aeadTagSize)
+	header[0] = deterministicVersion
+	header[1] = byte(AES256GCMSIV)
+	nonce := make([]byte, gcmSivNonceSize)
+	return aead.Seal(header, nonce, msg, symmAssociatedData(header, associatedData)), nil
+}
+
+// DecryptDeterministic Decrypts data encrypted by EncryptDeterministic
+func DecryptDeterministic(encryptedMsg, privKey, associatedData []byte) ([]byte, error) {
+	if len(privKey) == 0 {
+		return nil, ErrEmptyKey
+	}
+	if len(encryptedMsg) < 2+aeadTagSize {
+		return nil, ErrCiphertextTooShort
+	}
+	if encryptedMsg[0] != deterministicVersion {
+		return nil, ErrUnsupportedVersion
+	}
+	if AEADAlgorithm(encryptedMsg[1]) != AES256GCMSIV {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	aead, err := newDeterministicAEAD(privKey)
+	if err != nil {
+		return nil, err
+	}
+	header := encryptedMsg[:2]
+	nonce := make([]byte, gcmSivNonceSize)
+	return aead.Open(nil, nonce, encryptedMsg[2:], symmAssociatedData(header, associatedData))
+}
+
+// newDeterministicAEAD derives the deterministic encryption key. It differs from the keys of EncryptUsingSymmKey,
+// so the same secret can be used for both
+func newDeterministicAEAD(secret []byte) (cipher.AEAD, error) {
+	info := []byte{'s', 'k', 'd', 'e', 't', deterministicVersion, byte(AES256GCMSIV)}
+	key, err := deriveKey(secret, nil, info, symmKeySize)
+	if err != nil {
+		return nil, err
+	}
+	return newGCMSIV(key)
+}
@@ -0,0 +1,73 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+)
+
+// GenerateEd25519KeyPair generates a pub/priv ed25519 key pair
+func GenerateEd25519KeyPair() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	return ed25519.GenerateKey(rand.Reader)
+}
+
+// SignEd25519 returns the deterministic ed25519 signature of the message
+func SignEd25519(msg []byte, key ed25519.PrivateKey) []byte {
+	return ed25519.Sign(key, msg)
+}
+
+// VerifyEd25519 checks if a message is signed by a given ed25519 Public Key
+func VerifyEd25519(msg []byte, sig []byte, pk ed25519.PublicKey) bool {
+	return len(pk) == ed25519.PublicKeySize && ed25519.Verify(pk, msg, sig)
+}
+
+// EncodeEd25519PrivateKeyToPem encodes ed25519 private key to PKCS#8 pem format. The PKIX pem of the public key is returned too
+func EncodeEd25519PrivateKeyToPem(privateKey ed25519.PrivateKey) ([]byte, []byte, error) {
+	x509EncodedPriv, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	pemEncodedPriv := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: x509EncodedPriv})
+
+	x509EncodedPub, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+	pemEncodedPub := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: x509EncodedPub})
+
+	return pemEncodedPriv, pemEncodedPub, nil
+}
+
+// DecodeEd25519PrivateKeyFromPem decodes pem private key
+func DecodeEd25519PrivateKeyFromPem(pemEncodedPrivKey []byte) (ed25519.PrivateKey, error) {
+	if block, _ := pem.Decode(pemEncodedPrivKey); block == nil {
+		return nil, errors.New("failed to parse PEM block containing the key")
+	}
+	privateKey, err := ParsePrivateKey(pemEncodedPrivKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	edKey, ok := privateKey.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("Key type is not Ed25519")
+	}
+	return edKey, nil
+}
+
+// DecodeEd25519PublicKeyFromPem decodes pem public key
+func DecodeEd25519PublicKeyFromPem(pemEncodedPubKey []byte) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode(pemEncodedPubKey); block == nil {
+		return nil, errors.New("failed to parse PEM block containing the key")
+	}
+	publicKey, err := ParsePublicKey(pemEncodedPubKey)
+	if err != nil {
+		return nil, err
+	}
+	edKey, ok := publicKey.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("Key type is not Ed25519")
+	}
+	return edKey, nil
+}
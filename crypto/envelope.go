@@ -0,0 +1,322 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+)
+
+// Envelope encryption encrypts every message with a fresh random data key and stores the data key
+// wrapped by a long lived key next to the ciphertext. The compact envelope format is:
+// version | keyNameLen | keyName | keyIDLen | keyID | wrapAlgorithm | wrappedKeyLen | wrappedKey | ciphertext
+// Version 1 envelopes have no key id and are still accepted
+
+// envelopeVersion is the current version of the envelope format
+const envelopeVersion byte = 2
+
+// envelopeVersionNoKeyID is the version of envelopes that do not carry a key id
+const envelopeVersionNoKeyID byte = 1
+
+// envelopeDataKeySize is the size of the random per message data key
+const envelopeDataKeySize = 32
+
+// KeyWrapAlgorithm defines how the data key of an envelope is wrapped
+type KeyWrapAlgorithm byte
+
+const (
+	// RsaOaepSha256 wraps the data key using RSA-OAEP with SHA-256
+	RsaOaepSha256 KeyWrapAlgorithm = iota + 1
+	// EcdhEsHkdf wraps the data key using ephemeral-static ECDH, HKDF and the symmetric aead
+	EcdhEsHkdf
+	// SymmKeyWrap wraps the data key with a secret key using the symmetric aead
+	SymmKeyWrap
+)
+
+var (
+	// ErrInvalidEnvelope is returned when an envelope can not be parsed
+	ErrInvalidEnvelope = errors.New("invalid envelope")
+	// ErrUnsupportedKeyType is returned when an operation does not support the given key type
+	ErrUnsupportedKeyType = errors.New("unsupported key type")
+)
+
+// Envelope is an encrypted message together with the wrapped data key and the name and version of the wrapping key
+type Envelope struct {
+	KeyName       string
+	KeyID         string
+	WrapAlgorithm KeyWrapAlgorithm
+	WrappedKey    []byte
+	Ciphertext    []byte
+	// version of the format the envelope was parsed from. The header is authenticated, so it must be reproduced exactly
+	version byte
+}
+
+// header returns the serialized envelope without the ciphertext
+func (e *Envelope) header() ([]byte, error) {
+	version := e.version
+	if version == 0 {
+		version = envelopeVersion
+	}
+	if len(e.KeyName) > 255 || len(e.KeyID) > 255 || len(e.WrappedKey) > 65535 {
+		return nil, ErrInvalidEnvelope
+	}
+	if version == envelopeVersionNoKeyID && e.KeyID != "" {
+		return nil, ErrInvalidEnvelope
+	}
+
+	header := make([]byte, 0, 6+len(e.KeyName)+len(e.KeyID)+len(e.WrappedKey))
+	header = append(header, version, byte(len(e.KeyName)))
+	header = append(header, e.KeyName...)
+	if version != envelopeVersionNoKeyID {
+		header = append(header, byte(len(e.KeyID)))
+		header = append(header, e.KeyID...)
+	}
+	header = append(header, byte(e.WrapAlgorithm), 0, 0)
+	binary.BigEndian.PutUint16(header[len(header)-2:], uint16(len(e.WrappedKey)))
+	return append(header, e.WrappedKey...), nil
+}
+
+// Marshal serializes the envelope into its compact binary format
+func (e *Envelope) Marshal() ([]byte, error) {
+	header, err := e.header()
+	if err != nil {
+		return nil, err
+	}
+	return append(header, e.Ciphertext...), nil
+}
+
+// ParseEnvelope parses an envelope from its compact binary format
+func ParseEnvelope(data []byte) (*Envelope, error) {
+	if len(data) < 2 {
+		return nil, ErrInvalidEnvelope
+	}
+	version := data[0]
+	if version != envelopeVersion && version != envelopeVersionNoKeyID {
+		return nil, ErrUnsupportedVersion
+	}
+	nameLen := int(data[1])
+	data = data[2:]
+	if len(data) < nameLen+1 {
+		return nil, ErrInvalidEnvelope
+	}
+	keyName := string(data[:nameLen])
+	data = data[nameLen:]
+
+	var keyID string
+	if version != envelopeVersionNoKeyID {
+		idLen := int(data[0])
+		data = data[1:]
+		if len(data) < idLen {
+			return nil, ErrInvalidEnvelope
+		}
+		keyID = string(data[:idLen])
+		data = data[idLen:]
+	}
+
+	if len(data) < 3 {
+		return nil, ErrInvalidEnvelope
+	}
+
+	wrapAlgorithm := KeyWrapAlgorithm(data[0])
+	wrappedLen := int(binary.BigEndian.Uint16(data[1:3]))
+	data = data[3:]
+	if len(data) < wrappedLen {
+		return nil, ErrInvalidEnvelope
+	}
+
+	return &Envelope{
+		KeyName:       keyName,
+		KeyID:         keyID,
+		WrapAlgorithm: wrapAlgorithm,
+		WrappedKey:    data[:wrappedLen],
+		Ciphertext:    data[wrappedLen:],
+		version:       version,
+	}, nil
+}
+
+// SealEnvelope encrypts the message with a random data key and wraps the data key with the wrapping key.
+// See WrapDataKey for the supported wrapping keys
+func SealEnvelope(keyName, keyID string, wrappingKey crypto.PublicKey, msg, associatedData []byte) (*Envelope, error) {
+	dataKey := make([]byte, envelopeDataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	wrapAlgorithm, wrappedKey, err := WrapDataKey(dataKey, wrappingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope := &Envelope{
+		KeyName:       keyName,
+		KeyID:         keyID,
+		WrapAlgorithm: wrapAlgorithm,
+		WrappedKey:    wrappedKey,
+	}
+	header, err := envelope.header()
+	if err != nil {
+		return nil, err
+	}
+
+	envelope.Ciphertext, err = EncryptUsingSymmKeyWithConfig(msg, dataKey, SymmKeyConfig{
+		Algorithm:      DefaultSymmKeyConfig.Algorithm,
+		AssociatedData: symmAssociatedData(header, associatedData),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return envelope, nil
+}
+
+// OpenEnvelope unwraps the data key of the envelope with the private key or secret and decrypts the message
+func OpenEnvelope(envelope *Envelope, priv crypto.PrivateKey, associatedData []byte) ([]byte, error) {
+	header, err := envelope.header()
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := UnwrapDataKey(envelope.WrapAlgorithm, envelope.WrappedKey, priv)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecryptUsingSymmKeyWithConfig(envelope.Ciphertext, dataKey, SymmKeyConfig{
+		AssociatedData: symmAssociatedData(header, associatedData),
+	})
+}
+
+// WrapDataKey wraps the data key with the wrapping key. RSA public keys wrap using RSA-OAEP,
+// ECDSA public keys using ECDH with an ephemeral key and []byte secrets using the symmetric aead
+func WrapDataKey(dataKey []byte, wrappingKey crypto.PublicKey) (KeyWrapAlgorithm, []byte, error) {
+	switch pub := wrappingKey.(type) {
+	case *rsa.PublicKey:
+		wrappedKey, err := EncryptRsa(dataKey, pub, oaepSha256Options)
+		return RsaOaepSha256, wrappedKey, err
+	case *ecdsa.PublicKey:
+		wrappedKey, err := wrapDataKeyEcdh(dataKey, pub)
+		return EcdhEsHkdf, wrappedKey, err
+	case []byte:
+		wrappedKey, err := EncryptUsingSymmKey(dataKey, pub)
+		return SymmKeyWrap, wrappedKey, err
+	default:
+		return 0, nil, ErrUnsupportedKeyType
+	}
+}
+
+// UnwrapDataKey unwraps a data key wrapped by WrapDataKey using the private key
+func UnwrapDataKey(wrapAlgorithm KeyWrapAlgorithm, wrappedKey []byte, priv crypto.PrivateKey) ([]byte, error) {
+	switch wrapAlgorithm {
+	case RsaOaepSha256:
+		key, ok := priv.(*rsa.PrivateKey)
+		if !ok {
+			return nil, ErrUnsupportedKeyType
+		}
+		return DecryptRsa(wrappedKey, key, oaepSha256Options)
+	case EcdhEsHkdf:
+		key, ok := priv.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, ErrUnsupportedKeyType
+		}
+		return unwrapDataKeyEcdh(wrappedKey, key)
+	case SymmKeyWrap:
+		key, ok := priv.([]byte)
+		if !ok {
+			return nil, ErrUnsupportedKeyType
+		}
+		return DecryptUsingSymmKey(wrappedKey, key)
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+}
+
+// wrapDataKeyEcdh wraps the data key with a key derived from an ephemeral ECDH exchange.
+// The output is the uncompressed ephemeral public key followed by the encrypted data key
+func wrapDataKeyEcdh(dataKey []byte, pub *ecdsa.PublicKey) ([]byte, error) {
+	ephemeral, err := ecdsa.GenerateKey(pub.Curve, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	ephemeralPub := elliptic.Marshal(pub.Curve, ephemeral.X, ephemeral.Y)
+
+	kek, err := ecdhKeyEncryptionKey(pub.Curve, ephemeral.D.Bytes(), pub, ephemeralPub)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedKey, err := EncryptUsingSymmKey(dataKey, kek)
+	if err != nil {
+		return nil, err
+	}
+	return append(ephemeralPub, encryptedKey...), nil
+}
+
+// unwrapDataKeyEcdh unwraps a data key wrapped by wrapDataKeyEcdh
+func unwrapDataKeyEcdh(wrappedKey []byte, priv *ecdsa.PrivateKey) ([]byte, error) {
+	pointSize := 1 + 2*((priv.Curve.Params().BitSize+7)/8)
+	if len(wrappedKey) < pointSize {
+		return nil, ErrInvalidEnvelope
+	}
+	ephemeralPub := wrappedKey[:pointSize]
+
+	x, y := elliptic.Unmarshal(priv.Curve, ephemeralPub)
+	if x == nil {
+		return nil, ErrInvalidEnvelope
+	}
+
+	kek, err := ecdhKeyEncryptionKey(priv.Curve, priv.D.Bytes(), &ecdsa.PublicKey{Curve: priv.Curve, X: x, Y: y}, ephemeralPub)
+	if err != nil {
+		return nil, err
+	}
+	return DecryptUsingSymmKey(wrappedKey[pointSize:], kek)
+}
+
+// ecdhKeyEncryptionKey computes the ECDH shared secret and derives the key encryption key from it.
+// The ephemeral public key is used as salt so that the key is bound to the exchange
+func ecdhKeyEncryptionKey(curve elliptic.Curve, scalar []byte, peer *ecdsa.PublicKey, ephemeralPub []byte) ([]byte, error) {
+	sx, _ := curve.ScalarMult(peer.X, peer.Y, scalar)
+	shared := make([]byte, (curve.Params().BitSize+7)/8)
+	sx.FillBytes(shared)
+	return deriveKey(shared, ephemeralPub, []byte("skenvelope ecdh"), symmKeySize)
+}
+
+// EncryptEnvelope encrypts the message with a random data key wrapped by the active version of the named key
+// and returns the serialized envelope. The envelope carries the key name and version, so DecryptEnvelope
+// picks the right key even after rotation
+func (store *KeyStore) EncryptEnvelope(keyName string, msg, associatedData []byte) ([]byte, error) {
+	version, ok := store.activeKeyVersion(keyName)
+	if !ok {
+		return nil, ErrNoActiveKey
+	}
+	wrappingKey := version.Key.Public()
+	if version.Key.Type() == KeyTypeSecret {
+		wrappingKey = version.Key.Private()
+	}
+	envelope, err := SealEnvelope(keyName, version.ID, wrappingKey, msg, associatedData)
+	if err != nil {
+		return nil, err
+	}
+	return envelope.Marshal()
+}
+
+// DecryptEnvelope decrypts a serialized envelope using the key version named in it. The version must not be retired
+func (store *KeyStore) DecryptEnvelope(data, associatedData []byte) ([]byte, error) {
+	envelope, err := ParseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	versions := store.usableVersions(envelope.KeyName, envelope.KeyID)
+	if len(versions) == 0 {
+		return nil, ErrKeyNotFound
+	}
+	// Envelopes without a key id are tried against every usable version
+	for _, version := range versions {
+		msg, err := OpenEnvelope(envelope, version.Key.Private(), associatedData)
+		if err == nil || len(versions) == 1 {
+			return msg, err
+		}
+	}
+	return nil, ErrKeyNotFound
+}
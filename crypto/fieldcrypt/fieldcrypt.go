@@ -0,0 +1,312 @@
+package fieldcrypt
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"reflect"
+
+	"github.com/adityak368/swissknife/crypto"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Struct tags
+const (
+	// EncryptTag marks a string or []byte field for encryption. The value is "true" for randomized encryption
+	// or "deterministic" for encryption that allows equality lookups
+	EncryptTag = "encrypt"
+	// BlindIndexTag marks a string or []byte field that receives the blind index of the named sibling field
+	BlindIndexTag = "blindindex"
+)
+
+var (
+	// ErrNotStructPointer is returned when the value is not a non nil pointer to a struct
+	ErrNotStructPointer = errors.New("fieldcrypt requires a pointer to a struct")
+	// ErrUnsupportedField is returned when a tagged field is not a string or []byte
+	ErrUnsupportedField = errors.New("encrypted and blind index fields must be string or []byte")
+	// ErrUnknownIndexField is returned when a blind index names a field that does not exist
+	ErrUnknownIndexField = errors.New("blind index names an unknown field")
+	// ErrInvalidTag is returned for an encrypt tag other than "true" or "deterministic"
+	ErrInvalidTag = errors.New("invalid encrypt tag")
+	// ErrSharedStruct is returned when a struct is reached more than once, Ex: through a cycle of pointers
+	ErrSharedStruct = errors.New("fieldcrypt requires every struct to be reached only once")
+)
+
+// Config defines the field encryption config
+type Config struct {
+	// Algorithm is the aead of randomized fields. Deterministic fields always use AES-256-GCM-SIV
+	Algorithm crypto.AEADAlgorithm
+	// IndexSize is the number of bytes of a blind index. Shorter indexes collide more often and reveal less
+	IndexSize int
+}
+
+// DefaultConfig defines the default field encryption config
+var DefaultConfig = Config{
+	Algorithm: crypto.XChaCha20Poly1305,
+	IndexSize: 32,
+}
+
+// Encryptor encrypts and decrypts the tagged fields of structs in place. String fields hold base64 ciphertext,
+// []byte fields raw ciphertext. The path of the field, Ex: "Address.Street", is bound as associated data,
+// so a ciphertext can not be moved to another field. Empty values are left empty.
+//
+//	type User struct {
+//		Email      string `encrypt:"deterministic"`
+//		EmailIndex string `blindindex:"Email"`
+//		Phone      string `encrypt:"true"`
+//	}
+type Encryptor struct {
+	encryptionKey []byte
+	indexKey      []byte
+	config        Config
+}
+
+// NewEncryptor Creates a new field encryptor with the default config. Separate keys for encryption and
+// blind indexes are derived from the key
+func NewEncryptor(key []byte) (*Encryptor, error) {
+	return NewEncryptorWithConfig(key, DefaultConfig)
+}
+
+// NewEncryptorWithConfig Creates a new field encryptor with the config
+func NewEncryptorWithConfig(key []byte, config Config) (*Encryptor, error) {
+	if len(key) == 0 {
+		return nil, crypto.ErrEmptyKey
+	}
+	if config.Algorithm == 0 {
+		config.Algorithm = DefaultConfig.Algorithm
+	}
+	if config.IndexSize <= 0 || config.IndexSize > sha256.Size {
+		config.IndexSize = DefaultConfig.IndexSize
+	}
+	encryptionKey, err := deriveKey(key, "skfield encryption")
+	if err != nil {
+		return nil, err
+	}
+	indexKey, err := deriveKey(key, "skfield blind index")
+	if err != nil {
+		return nil, err
+	}
+	return &Encryptor{
+		encryptionKey: encryptionKey,
+		indexKey:      indexKey,
+		config:        config,
+	}, nil
+}
+
+// Encrypt computes the blind indexes and encrypts the tagged fields of the struct v points to.
+// On error the struct may be partially encrypted
+func (e *Encryptor) Encrypt(v interface{}) error {
+	root, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	return e.walk(root, "", true, make(map[structAddr]bool))
+}
+
+// Decrypt decrypts the tagged fields of the struct v points to. Blind indexes are left as they are.
+// On error the struct may be partially decrypted
+func (e *Encryptor) Decrypt(v interface{}) error {
+	root, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	return e.walk(root, "", false, make(map[structAddr]bool))
+}
+
+// BlindIndex returns the blind index of the value of the field path, Ex: "Email", to look up a record by equality
+func (e *Encryptor) BlindIndex(path, value string) string {
+	if value == "" {
+		return ""
+	}
+	return hex.EncodeToString(e.blindIndex(path, []byte(value)))
+}
+
+// DeterministicValue returns the ciphertext a deterministic string field with the path holds for the value,
+// to look up a record by equality
+func (e *Encryptor) DeterministicValue(path, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	ciphertext, err := crypto.EncryptDeterministic([]byte(value), e.encryptionKey, []byte(path))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// structAddr identifies a walked struct. The type tells a struct apart from a nested struct at the same address
+type structAddr struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// walk encrypts or decrypts the fields of the struct and the structs it contains
+func (e *Encryptor) walk(v reflect.Value, prefix string, encrypt bool, visited map[structAddr]bool) error {
+	t := v.Type()
+
+	// A struct reached twice would be transformed twice and a cycle of pointers would never end
+	addr := structAddr{addr: v.UnsafeAddr(), typ: t}
+	if visited[addr] {
+		return ErrSharedStruct
+	}
+	visited[addr] = true
+
+	// Blind indexes are computed from the plaintext, so before any field of the struct is encrypted
+	if encrypt {
+		for i := 0; i < t.NumField(); i++ {
+			source, ok := t.Field(i).Tag.Lookup(BlindIndexTag)
+			if !ok || !v.Field(i).CanSet() {
+				continue
+			}
+			sourceField := v.FieldByName(source)
+			if !sourceField.IsValid() {
+				return ErrUnknownIndexField
+			}
+			value, err := fieldBytes(sourceField)
+			if err != nil {
+				return err
+			}
+			index := ""
+			if len(value) > 0 {
+				index = hex.EncodeToString(e.blindIndex(prefix+source, value))
+			}
+			if err := setFieldBytes(v.Field(i), []byte(index), false); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+		path := prefix + t.Field(i).Name
+
+		tag, ok := t.Field(i).Tag.Lookup(EncryptTag)
+		if !ok {
+			if nested, ok := nestedStruct(field); ok {
+				if err := e.walk(nested, path+".", encrypt, visited); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if tag != "true" && tag != "deterministic" {
+			return ErrInvalidTag
+		}
+		if err := e.transformField(field, path, tag == "deterministic", encrypt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transformField encrypts or decrypts a single field
+func (e *Encryptor) transformField(field reflect.Value, path string, deterministic, encrypt bool) error {
+	isString := field.Kind() == reflect.String
+	value, err := fieldBytes(field)
+	if err != nil || len(value) == 0 {
+		return err
+	}
+	ad := []byte(path)
+
+	var result []byte
+	if encrypt {
+		if deterministic {
+			result, err = crypto.EncryptDeterministic(value, e.encryptionKey, ad)
+		} else {
+			result, err = crypto.EncryptUsingSymmKeyWithConfig(value, e.encryptionKey, crypto.SymmKeyConfig{
+				Algorithm:      e.config.Algorithm,
+				AssociatedData: ad,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		return setFieldBytes(field, result, isString)
+	}
+
+	if isString {
+		if value, err = base64.StdEncoding.DecodeString(string(value)); err != nil {
+			return err
+		}
+	}
+	if deterministic {
+		result, err = crypto.DecryptDeterministic(value, e.encryptionKey, ad)
+	} else {
+		result, err = crypto.DecryptUsingSymmKeyWithConfig(value, e.encryptionKey, crypto.SymmKeyConfig{AssociatedData: ad})
+	}
+	if err != nil {
+		return err
+	}
+	return setFieldBytes(field, result, false)
+}
+
+// blindIndex returns the truncated HMAC-SHA256 of the path and the value
+func (e *Encryptor) blindIndex(path string, value []byte) []byte {
+	msg := make([]byte, 0, len(path)+1+len(value))
+	msg = append(msg, path...)
+	msg = append(msg, 0)
+	msg = append(msg, value...)
+	return crypto.HMAC(msg, e.indexKey, sha256.New)[:e.config.IndexSize]
+}
+
+// structValue returns the struct v points to
+func structValue(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, ErrNotStructPointer
+	}
+	return rv.Elem(), nil
+}
+
+// nestedStruct returns the struct a field holds directly or through a non nil pointer
+func nestedStruct(field reflect.Value) (reflect.Value, bool) {
+	if field.Kind() == reflect.Ptr && !field.IsNil() {
+		field = field.Elem()
+	}
+	return field, field.Kind() == reflect.Struct
+}
+
+// fieldBytes returns the value of a string or []byte field
+func fieldBytes(field reflect.Value) ([]byte, error) {
+	switch {
+	case field.Kind() == reflect.String:
+		return []byte(field.String()), nil
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Uint8:
+		return field.Bytes(), nil
+	default:
+		return nil, ErrUnsupportedField
+	}
+}
+
+// setFieldBytes sets a string or []byte field. Ciphertext is base64 encoded for string fields
+func setFieldBytes(field reflect.Value, value []byte, encode bool) error {
+	switch {
+	case field.Kind() == reflect.String:
+		if encode {
+			field.SetString(base64.StdEncoding.EncodeToString(value))
+		} else {
+			field.SetString(string(value))
+		}
+		return nil
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Uint8:
+		field.SetBytes(value)
+		return nil
+	default:
+		return ErrUnsupportedField
+	}
+}
+
+// deriveKey derives a 32 byte sub key using HKDF-SHA512
+func deriveKey(key []byte, info string) ([]byte, error) {
+	derived := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha512.New, key, nil, []byte(info)), derived); err != nil {
+		return nil, err
+	}
+	return derived, nil
+}
@@ -0,0 +1,257 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// AES-256-GCM-SIV as specified in RFC 8452. The standard library does not ship it,
+// so it is implemented on top of crypto/aes here.
+
+const (
+	gcmSivKeySize   = 32
+	gcmSivNonceSize = 12
+	gcmSivTagSize   = 16
+	gcmSivMaxLength = 1 << 36
+)
+
+// gcmSiv implements the cipher.AEAD interface for AES-256-GCM-SIV
+type gcmSiv struct {
+	block cipher.Block
+}
+
+// newGCMSIV returns an AES-256-GCM-SIV aead for the given 32 byte key generating key
+func newGCMSIV(key []byte) (cipher.AEAD, error) {
+	if len(key) != gcmSivKeySize {
+		return nil, errors.New("gcmsiv: invalid key size")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &gcmSiv{block: block}, nil
+}
+
+// NonceSize implements cipher.AEAD
+func (g *gcmSiv) NonceSize() int {
+	return gcmSivNonceSize
+}
+
+// Overhead implements cipher.AEAD
+func (g *gcmSiv) Overhead() int {
+	return gcmSivTagSize
+}
+
+// Seal implements cipher.AEAD
+func (g *gcmSiv) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != gcmSivNonceSize {
+		panic("gcmsiv: incorrect nonce length given to GCM-SIV")
+	}
+	if uint64(len(plaintext)) > gcmSivMaxLength || uint64(len(additionalData)) > gcmSivMaxLength {
+		panic("gcmsiv: message too large for GCM-SIV")
+	}
+
+	authKey, encBlock := g.deriveKeys(nonce)
+	tag := g.tag(authKey, encBlock, nonce, plaintext, additionalData)
+
+	ret, out := sliceForAppend(dst, len(plaintext)+gcmSivTagSize)
+	gcmSivCtr(encBlock, out[:len(plaintext)], plaintext, tag)
+	copy(out[len(plaintext):], tag[:])
+	return ret
+}
+
+// Open implements cipher.AEAD
+func (g *gcmSiv) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != gcmSivNonceSize {
+		panic("gcmsiv: incorrect nonce length given to GCM-SIV")
+	}
+	if len(ciphertext) < gcmSivTagSize ||
+		uint64(len(ciphertext)) > gcmSivMaxLength+gcmSivTagSize ||
+		uint64(len(additionalData)) > gcmSivMaxLength {
+		return nil, errors.New("gcmsiv: message authentication failed")
+	}
+
+	var tag [gcmSivTagSize]byte
+	copy(tag[:], ciphertext[len(ciphertext)-gcmSivTagSize:])
+	ciphertext = ciphertext[:len(ciphertext)-gcmSivTagSize]
+
+	authKey, encBlock := g.deriveKeys(nonce)
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	gcmSivCtr(encBlock, out, ciphertext, tag)
+
+	expectedTag := g.tag(authKey, encBlock, nonce, out, additionalData)
+	if subtle.ConstantTimeCompare(expectedTag[:], tag[:]) != 1 {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, errors.New("gcmsiv: message authentication failed")
+	}
+	return ret, nil
+}
+
+// deriveKeys derives the per nonce message authentication and encryption keys
+func (g *gcmSiv) deriveKeys(nonce []byte) ([16]byte, cipher.Block) {
+	var input, output [aes.BlockSize]byte
+	var authKey [16]byte
+	var encKey [32]byte
+
+	copy(input[4:], nonce)
+	for i := uint32(0); i < 6; i++ {
+		binary.LittleEndian.PutUint32(input[:4], i)
+		g.block.Encrypt(output[:], input[:])
+		if i < 2 {
+			copy(authKey[i*8:], output[:8])
+		} else {
+			copy(encKey[(i-2)*8:], output[:8])
+		}
+	}
+
+	// The key size is always valid, so this can not fail
+	encBlock, _ := aes.NewCipher(encKey[:])
+	return authKey, encBlock
+}
+
+// tag computes the authentication tag over the plaintext and additional data
+func (g *gcmSiv) tag(authKey [16]byte, encBlock cipher.Block, nonce, plaintext, additionalData []byte) [gcmSivTagSize]byte {
+	p := newPolyval(authKey)
+	p.update(additionalData)
+	p.update(plaintext)
+
+	var lengths [16]byte
+	binary.LittleEndian.PutUint64(lengths[:8], uint64(len(additionalData))*8)
+	binary.LittleEndian.PutUint64(lengths[8:], uint64(len(plaintext))*8)
+	p.update(lengths[:])
+
+	s := p.sum()
+	for i := range nonce {
+		s[i] ^= nonce[i]
+	}
+	s[15] &= 0x7f
+
+	var tag [gcmSivTagSize]byte
+	encBlock.Encrypt(tag[:], s[:])
+	return tag
+}
+
+// gcmSivCtr xors src with the key stream derived from the tag into dst
+func gcmSivCtr(block cipher.Block, dst, src []byte, tag [gcmSivTagSize]byte) {
+	var counter, keyStream [aes.BlockSize]byte
+	copy(counter[:], tag[:])
+	counter[15] |= 0x80
+
+	for len(src) > 0 {
+		block.Encrypt(keyStream[:], counter[:])
+		binary.LittleEndian.PutUint32(counter[:4], binary.LittleEndian.Uint32(counter[:4])+1)
+
+		n := len(src)
+		if n > aes.BlockSize {
+			n = aes.BlockSize
+		}
+		for i := 0; i < n; i++ {
+			dst[i] = src[i] ^ keyStream[i]
+		}
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+// polyval implements the POLYVAL universal hash over GF(2^128) defined by
+// x^128 + x^127 + x^126 + x^121 + 1. Field elements are kept as little endian lo/hi halves.
+type polyval struct {
+	// h is the hash key multiplied by x^-128 so that a plain field multiplication yields dot(a, H)
+	hLo, hHi uint64
+	// accumulator
+	sLo, sHi uint64
+}
+
+// newPolyval creates a new POLYVAL instance for the given key
+func newPolyval(key [16]byte) *polyval {
+	lo := binary.LittleEndian.Uint64(key[:8])
+	hi := binary.LittleEndian.Uint64(key[8:])
+	for i := 0; i < 128; i++ {
+		lo, hi = polyvalDivX(lo, hi)
+	}
+	return &polyval{hLo: lo, hHi: hi}
+}
+
+// update absorbs data, zero padding the final partial block
+func (p *polyval) update(data []byte) {
+	var block [16]byte
+	for len(data) > 0 {
+		n := copy(block[:], data)
+		for i := n; i < 16; i++ {
+			block[i] = 0
+		}
+		data = data[n:]
+
+		p.sLo ^= binary.LittleEndian.Uint64(block[:8])
+		p.sHi ^= binary.LittleEndian.Uint64(block[8:])
+		p.sLo, p.sHi = polyvalMul(p.sLo, p.sHi, p.hLo, p.hHi)
+	}
+}
+
+// sum returns the current hash value
+func (p *polyval) sum() [16]byte {
+	var out [16]byte
+	binary.LittleEndian.PutUint64(out[:8], p.sLo)
+	binary.LittleEndian.PutUint64(out[8:], p.sHi)
+	return out
+}
+
+// polyvalReduction holds the x^127 + x^126 + x^121 terms of the field polynomial in the high half
+const polyvalReduction = 1<<63 | 1<<62 | 1<<57
+
+// polyvalMulX multiplies a field element by x
+func polyvalMulX(lo, hi uint64) (uint64, uint64) {
+	carry := hi >> 63
+	hi = hi<<1 | lo>>63
+	lo <<= 1
+	// x^128 = x^127 + x^126 + x^121 + 1
+	hi ^= polyvalReduction & -carry
+	lo ^= carry
+	return lo, hi
+}
+
+// polyvalDivX multiplies a field element by x^-1
+func polyvalDivX(lo, hi uint64) (uint64, uint64) {
+	carry := lo & 1
+	lo = lo>>1 | hi<<63
+	hi >>= 1
+	// x^-1 = x^127 + x^126 + x^125 + x^120
+	hi ^= (1<<63 | 1<<62 | 1<<61 | 1<<56) & -carry
+	return lo, hi
+}
+
+// polyvalMul multiplies two field elements
+func polyvalMul(aLo, aHi, bLo, bHi uint64) (uint64, uint64) {
+	var lo, hi uint64
+	for i := 0; i < 64; i++ {
+		mask := -(aLo >> i & 1)
+		lo ^= bLo & mask
+		hi ^= bHi & mask
+		bLo, bHi = polyvalMulX(bLo, bHi)
+	}
+	for i := 0; i < 64; i++ {
+		mask := -(aHi >> i & 1)
+		lo ^= bLo & mask
+		hi ^= bHi & mask
+		bLo, bHi = polyvalMulX(bLo, bHi)
+	}
+	return lo, hi
+}
+
+// sliceForAppend extends the input slice by n bytes. head is the full extended slice,
+// tail is the appended part
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
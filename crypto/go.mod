@@ -2,6 +2,13 @@ module github.com/adityak368/swissknife/crypto
 
 go 1.16
 
-replace github.com/adityak368/swissknife/crypto => ./
+replace (
+	github.com/adityak368/swissknife/crypto => ./
+	github.com/adityak368/swissknife/response => ../response
+)
 
-require golang.org/x/crypto v0.0.0-20201012173705-84dcc777aaee
+require (
+	github.com/adityak368/swissknife/response v0.0.0-20201017141410-95d62b8ed51b
+	github.com/labstack/echo/v4 v4.2.2
+	golang.org/x/crypto v0.0.0-20201012173705-84dcc777aaee
+)
@@ -0,0 +1,12 @@
+package echoerror
+
+import (
+	"github.com/adityak368/swissknife/response"
+	"github.com/labstack/echo/v4"
+)
+
+// Message writes the error as an untranslated response.Message. It is the default ErrorHandler
+// of the echo middlewares of the crypto module
+func Message(c echo.Context, err *response.Error) error {
+	return c.JSON(err.Code, err.ToMessage())
+}
@@ -0,0 +1,284 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"hash"
+)
+
+// KeyType defines the type of a key held by the keystore
+type KeyType string
+
+const (
+	// KeyTypeRsa is a rsa private key
+	KeyTypeRsa KeyType = "RSA"
+	// KeyTypeEcdsa is an ecdsa private key on P-256, P-384 or P-521
+	KeyTypeEcdsa KeyType = "EC"
+	// KeyTypeEd25519 is an ed25519 private key
+	KeyTypeEd25519 KeyType = "Ed25519"
+	// KeyTypeSecret is a raw secret used for HMAC and symmetric encryption
+	KeyTypeSecret KeyType = "Secret"
+)
+
+// Key defines a key held by the keystore
+type Key interface {
+	// Type returns the type of the key
+	Type() KeyType
+	// Private returns the underlying private key. For secret keys this is the raw []byte secret
+	Private() crypto.PrivateKey
+	// Public returns the public key. Secret keys have no public key and return nil
+	Public() crypto.PublicKey
+	// Sign signs the message. Secret keys compute a HMAC
+	Sign(msg []byte) ([]byte, error)
+	// Verify checks the signature of the message
+	Verify(msg, signature []byte) error
+}
+
+// NewKey wraps a private key into a Key. Supported are *rsa.PrivateKey, *ecdsa.PrivateKey,
+// ed25519.PrivateKey and []byte secrets
+func NewKey(privateKey crypto.PrivateKey) (Key, error) {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return &rsaKey{key: key}, nil
+	case *ecdsa.PrivateKey:
+		if _, err := ecdsaHash(key.Curve); err != nil {
+			return nil, err
+		}
+		return &ecdsaKey{key: key}, nil
+	case ed25519.PrivateKey:
+		if len(key) != ed25519.PrivateKeySize {
+			return nil, errors.New("invalid ed25519 private key size")
+		}
+		return ed25519Key(append([]byte(nil), key...)), nil
+	case *ed25519.PrivateKey:
+		return NewKey(*key)
+	case []byte:
+		if len(key) == 0 {
+			return nil, ErrEmptyKey
+		}
+		return secretKey(append([]byte(nil), key...)), nil
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+}
+
+// KeyID returns the identifier of a key. For asymmetric keys it is derived from the public key,
+// for secret keys from a HMAC of the secret
+func KeyID(key Key) (string, error) {
+	var sum []byte
+	if key.Type() == KeyTypeSecret {
+		sum = HMAC([]byte("swissknife key id"), key.Private().([]byte), sha256.New)
+	} else {
+		der, err := x509.MarshalPKIXPublicKey(key.Public())
+		if err != nil {
+			return "", err
+		}
+		digest := sha256.Sum256(der)
+		sum = digest[:]
+	}
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// VerifyWithPublicKey checks a signature made by the Sign method of the Key of the private key
+func VerifyWithPublicKey(pub crypto.PublicKey, msg, signature []byte) error {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		if err := Verify(msg, signature, pub); err != nil {
+			return ErrVerification
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		hashFunc, err := ecdsaHash(pub.Curve)
+		if err != nil {
+			return err
+		}
+		if !ecdsa.VerifyASN1(pub, digest(hashFunc, msg), signature) {
+			return ErrVerification
+		}
+		return nil
+	case ed25519.PublicKey:
+		if !VerifyEd25519(msg, signature, pub) {
+			return ErrVerification
+		}
+		return nil
+	default:
+		return ErrUnsupportedKeyType
+	}
+}
+
+// ParsePrivateKeyFromPemStr parses a rsa, ecdsa or ed25519 private key in PKCS#1, SEC1 or PKCS#8 pem format
+func ParsePrivateKeyFromPemStr(privPEM string) (Key, error) {
+	if block, _ := pem.Decode([]byte(privPEM)); block == nil {
+		return nil, errors.New("failed to parse PEM block containing the key")
+	}
+
+	privateKey, err := ParsePrivateKey([]byte(privPEM), nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewKey(privateKey)
+}
+
+// rsaKey is a rsa Key. It signs using PKCS#1 v1.5 with SHA-512 like Sign
+type rsaKey struct {
+	key *rsa.PrivateKey
+}
+
+// Type implements the Key interface
+func (k *rsaKey) Type() KeyType {
+	return KeyTypeRsa
+}
+
+// Private implements the Key interface
+func (k *rsaKey) Private() crypto.PrivateKey {
+	return k.key
+}
+
+// Public implements the Key interface
+func (k *rsaKey) Public() crypto.PublicKey {
+	return &k.key.PublicKey
+}
+
+// Sign implements the Key interface
+func (k *rsaKey) Sign(msg []byte) ([]byte, error) {
+	return Sign(msg, k.key)
+}
+
+// Verify implements the Key interface
+func (k *rsaKey) Verify(msg, signature []byte) error {
+	return Verify(msg, signature, &k.key.PublicKey)
+}
+
+// ecdsaKey is an ecdsa Key. It signs ASN.1 signatures using the hash matching the curve size
+type ecdsaKey struct {
+	key *ecdsa.PrivateKey
+}
+
+// Type implements the Key interface
+func (k *ecdsaKey) Type() KeyType {
+	return KeyTypeEcdsa
+}
+
+// Private implements the Key interface
+func (k *ecdsaKey) Private() crypto.PrivateKey {
+	return k.key
+}
+
+// Public implements the Key interface
+func (k *ecdsaKey) Public() crypto.PublicKey {
+	return &k.key.PublicKey
+}
+
+// Sign implements the Key interface
+func (k *ecdsaKey) Sign(msg []byte) ([]byte, error) {
+	hashFunc, err := ecdsaHash(k.key.Curve)
+	if err != nil {
+		return nil, err
+	}
+	return ecdsa.SignASN1(rand.Reader, k.key, digest(hashFunc, msg))
+}
+
+// Verify implements the Key interface
+func (k *ecdsaKey) Verify(msg, signature []byte) error {
+	hashFunc, err := ecdsaHash(k.key.Curve)
+	if err != nil {
+		return err
+	}
+	if !ecdsa.VerifyASN1(&k.key.PublicKey, digest(hashFunc, msg), signature) {
+		return ErrVerification
+	}
+	return nil
+}
+
+// ed25519Key is an ed25519 Key
+type ed25519Key ed25519.PrivateKey
+
+// Type implements the Key interface
+func (k ed25519Key) Type() KeyType {
+	return KeyTypeEd25519
+}
+
+// Private implements the Key interface. It returns a copy, so the stored key can not be modified
+func (k ed25519Key) Private() crypto.PrivateKey {
+	return append(ed25519.PrivateKey(nil), k...)
+}
+
+// Public implements the Key interface
+func (k ed25519Key) Public() crypto.PublicKey {
+	return ed25519.PrivateKey(k).Public()
+}
+
+// Sign implements the Key interface
+func (k ed25519Key) Sign(msg []byte) ([]byte, error) {
+	return ed25519.Sign(ed25519.PrivateKey(k), msg), nil
+}
+
+// Verify implements the Key interface
+func (k ed25519Key) Verify(msg, signature []byte) error {
+	if !ed25519.Verify(ed25519.PrivateKey(k).Public().(ed25519.PublicKey), msg, signature) {
+		return ErrVerification
+	}
+	return nil
+}
+
+// secretKey is a raw secret Key. It signs using HMAC-SHA512
+type secretKey []byte
+
+// Type implements the Key interface
+func (k secretKey) Type() KeyType {
+	return KeyTypeSecret
+}
+
+// Private implements the Key interface. It returns a copy, so the stored secret can not be modified
+func (k secretKey) Private() crypto.PrivateKey {
+	return append([]byte(nil), k...)
+}
+
+// Public implements the Key interface
+func (k secretKey) Public() crypto.PublicKey {
+	return nil
+}
+
+// Sign implements the Key interface
+func (k secretKey) Sign(msg []byte) ([]byte, error) {
+	return HMAC(msg, k, sha512.New), nil
+}
+
+// Verify implements the Key interface
+func (k secretKey) Verify(msg, signature []byte) error {
+	if !ValidMAC(msg, k, signature, sha512.New) {
+		return ErrVerification
+	}
+	return nil
+}
+
+// ecdsaHash returns the hash matching the size of the curve
+func ecdsaHash(curve elliptic.Curve) (func() hash.Hash, error) {
+	switch curve.Params().BitSize {
+	case 256:
+		return sha256.New, nil
+	case 384:
+		return sha512.New384, nil
+	case 521:
+		return sha512.New, nil
+	default:
+		return nil, errors.New("unsupported elliptic curve")
+	}
+}
+
+// digest hashes the message
+func digest(hashFunc func() hash.Hash, msg []byte) []byte {
+	h := hashFunc()
+	h.Write(msg)
+	return h.Sum(nil)
+}
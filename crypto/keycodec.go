@@ -0,0 +1,331 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Errors returned by the key codec
+var (
+	ErrInvalidKeyData       = errors.New("failed to parse key data")
+	ErrUnsupportedKeyFormat = errors.New("unsupported key format")
+	ErrPassphraseRequired   = errors.New("key is encrypted, a passphrase is required")
+	ErrIncorrectPassphrase  = errors.New("incorrect passphrase")
+	ErrTooManyIterations    = errors.New("pbkdf2 iteration count exceeds 10000000")
+)
+
+// KeyFormat defines the encoding structure of a key
+type KeyFormat byte
+
+const (
+	// KeyFormatPkcs1 is the PKCS#1 format of rsa private and public keys
+	KeyFormatPkcs1 KeyFormat = iota + 1
+	// KeyFormatSec1 is the SEC 1 format of ecdsa private keys
+	KeyFormatSec1
+	// KeyFormatPkcs8 is the PKCS#8 format of private keys. It is encrypted if a passphrase is set
+	KeyFormatPkcs8
+	// KeyFormatPkix is the PKIX (SubjectPublicKeyInfo) format of public keys
+	KeyFormatPkix
+	// KeyFormatOpenSSH is the authorized_keys format of public keys
+	KeyFormatOpenSSH
+)
+
+// KeyEncoding defines whether a key is exported as PEM or raw DER
+type KeyEncoding byte
+
+const (
+	// KeyEncodingPem exports PEM blocks
+	KeyEncodingPem KeyEncoding = iota + 1
+	// KeyEncodingDer exports raw DER
+	KeyEncodingDer
+)
+
+// KeyExportConfig defines the config for exporting keys
+type KeyExportConfig struct {
+	// Format of the key. Zero exports private keys as PKCS#8 and public keys as PKIX
+	Format KeyFormat
+	// Encoding of the key. It is ignored for OpenSSH public keys
+	Encoding KeyEncoding
+	// Passphrase encrypts PKCS#8 private keys using PBES2 with PBKDF2-HMAC-SHA256 and AES-256-CBC
+	Passphrase []byte
+	// Iterations is the PBKDF2 iteration count of encrypted keys. It is at most 10,000,000
+	Iterations int
+}
+
+// DefaultKeyExportConfig defines the default key export config
+var DefaultKeyExportConfig = KeyExportConfig{
+	Encoding:   KeyEncodingPem,
+	Iterations: 600000,
+}
+
+// ParsePrivateKey parses a rsa, ecdsa or ed25519 private key. The format is detected, PEM and DER encoded
+// PKCS#1, SEC 1, PKCS#8, encrypted PKCS#8, legacy encrypted PEM and OpenSSH private keys are accepted.
+// The passphrase is only used for encrypted keys
+func ParsePrivateKey(data, passphrase []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return parsePrivateKeyDer(data, passphrase)
+	}
+
+	var privateKey crypto.PrivateKey
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY", "EC PRIVATE KEY":
+		der := block.Bytes
+		// Legacy encrypted PEM is insecure but still written by older tools, so it is accepted for import only
+		if x509.IsEncryptedPEMBlock(block) {
+			if len(passphrase) == 0 {
+				return nil, ErrPassphraseRequired
+			}
+			if der, err = x509.DecryptPEMBlock(block, passphrase); err != nil {
+				return nil, ErrIncorrectPassphrase
+			}
+		}
+		if block.Type == "RSA PRIVATE KEY" {
+			privateKey, err = x509.ParsePKCS1PrivateKey(der)
+		} else {
+			privateKey, err = x509.ParseECPrivateKey(der)
+		}
+	case "PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "ENCRYPTED PRIVATE KEY":
+		if len(passphrase) == 0 {
+			return nil, ErrPassphraseRequired
+		}
+		var der []byte
+		if der, err = decryptPKCS8(block.Bytes, passphrase); err != nil {
+			return nil, err
+		}
+		if privateKey, err = x509.ParsePKCS8PrivateKey(der); err != nil {
+			return nil, ErrIncorrectPassphrase
+		}
+	case "OPENSSH PRIVATE KEY":
+		privateKey, err = parseOpenSSHPrivateKey(pem.EncodeToMemory(block), passphrase)
+	default:
+		return nil, errors.New("unsupported PEM block type " + block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return checkPrivateKey(privateKey)
+}
+
+// ParsePublicKey parses a rsa, ecdsa or ed25519 public key. The format is detected, PEM and DER encoded
+// PKIX and PKCS#1 public keys, certificates and OpenSSH authorized_keys lines are accepted
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		if pub, _, _, _, err := ssh.ParseAuthorizedKey(data); err == nil {
+			cryptoPub, ok := pub.(ssh.CryptoPublicKey)
+			if !ok {
+				return nil, ErrUnsupportedKeyType
+			}
+			return checkPublicKey(cryptoPub.CryptoPublicKey())
+		}
+		return parsePublicKeyDer(data)
+	}
+
+	var publicKey crypto.PublicKey
+	var err error
+	switch block.Type {
+	case "PUBLIC KEY":
+		publicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		publicKey, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			publicKey = cert.PublicKey
+		}
+	default:
+		return nil, errors.New("unsupported PEM block type " + block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return checkPublicKey(publicKey)
+}
+
+// MarshalPrivateKey exports a rsa, ecdsa or ed25519 private key, or the private key of a Key, in the format of the config
+func MarshalPrivateKey(key crypto.PrivateKey, config KeyExportConfig) ([]byte, error) {
+	if k, ok := key.(Key); ok {
+		key = k.Private()
+	}
+	key, err := checkPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if config.Format == 0 {
+		config.Format = KeyFormatPkcs8
+	}
+	if len(config.Passphrase) > 0 && config.Format != KeyFormatPkcs8 {
+		return nil, ErrUnsupportedKeyFormat
+	}
+
+	var der []byte
+	var blockType string
+	switch config.Format {
+	case KeyFormatPkcs1:
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, ErrUnsupportedKeyFormat
+		}
+		der, blockType = x509.MarshalPKCS1PrivateKey(rsaKey), "RSA PRIVATE KEY"
+	case KeyFormatSec1:
+		ecdsaKey, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, ErrUnsupportedKeyFormat
+		}
+		if der, err = x509.MarshalECPrivateKey(ecdsaKey); err != nil {
+			return nil, err
+		}
+		blockType = "EC PRIVATE KEY"
+	case KeyFormatPkcs8:
+		if der, err = x509.MarshalPKCS8PrivateKey(key); err != nil {
+			return nil, err
+		}
+		blockType = "PRIVATE KEY"
+		if len(config.Passphrase) > 0 {
+			if config.Iterations <= 0 {
+				config.Iterations = DefaultKeyExportConfig.Iterations
+			}
+			if der, err = encryptPKCS8(der, config.Passphrase, config.Iterations); err != nil {
+				return nil, err
+			}
+			blockType = "ENCRYPTED PRIVATE KEY"
+		}
+	default:
+		return nil, ErrUnsupportedKeyFormat
+	}
+	return encodeKey(der, blockType, config.Encoding), nil
+}
+
+// MarshalPublicKey exports a rsa, ecdsa or ed25519 public key in the format of the config
+func MarshalPublicKey(pub crypto.PublicKey, config KeyExportConfig) ([]byte, error) {
+	pub, err := checkPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	if config.Format == 0 {
+		config.Format = KeyFormatPkix
+	}
+
+	switch config.Format {
+	case KeyFormatPkix:
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return nil, err
+		}
+		return encodeKey(der, "PUBLIC KEY", config.Encoding), nil
+	case KeyFormatPkcs1:
+		rsaKey, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return nil, ErrUnsupportedKeyFormat
+		}
+		return encodeKey(x509.MarshalPKCS1PublicKey(rsaKey), "RSA PUBLIC KEY", config.Encoding), nil
+	case KeyFormatOpenSSH:
+		sshKey, err := ssh.NewPublicKey(pub)
+		if err != nil {
+			return nil, err
+		}
+		return ssh.MarshalAuthorizedKey(sshKey), nil
+	default:
+		return nil, ErrUnsupportedKeyFormat
+	}
+}
+
+// parsePrivateKeyDer detects the format of a DER encoded private key
+func parsePrivateKeyDer(der, passphrase []byte) (crypto.PrivateKey, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return checkPrivateKey(key)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return checkPrivateKey(key)
+	}
+	var info encryptedPrivateKeyInfo
+	if rest, err := asn1.Unmarshal(der, &info); err == nil && len(rest) == 0 && info.Algorithm.Algorithm.Equal(oidPBES2) {
+		if len(passphrase) == 0 {
+			return nil, ErrPassphraseRequired
+		}
+		decrypted, err := decryptPKCS8(der, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		key, err := x509.ParsePKCS8PrivateKey(decrypted)
+		if err != nil {
+			return nil, ErrIncorrectPassphrase
+		}
+		return checkPrivateKey(key)
+	}
+	return nil, ErrInvalidKeyData
+}
+
+// parsePublicKeyDer detects the format of a DER encoded public key
+func parsePublicKeyDer(der []byte) (crypto.PublicKey, error) {
+	if key, err := x509.ParsePKIXPublicKey(der); err == nil {
+		return checkPublicKey(key)
+	}
+	if key, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		return key, nil
+	}
+	return nil, ErrInvalidKeyData
+}
+
+// parseOpenSSHPrivateKey parses an OpenSSH private key
+func parseOpenSSHPrivateKey(pemBytes, passphrase []byte) (crypto.PrivateKey, error) {
+	if len(passphrase) == 0 {
+		key, err := ssh.ParseRawPrivateKey(pemBytes)
+		if _, ok := err.(*ssh.PassphraseMissingError); ok {
+			return nil, ErrPassphraseRequired
+		}
+		return key, err
+	}
+	key, err := ssh.ParseRawPrivateKeyWithPassphrase(pemBytes, passphrase)
+	if err == x509.IncorrectPasswordError {
+		return nil, ErrIncorrectPassphrase
+	}
+	return key, err
+}
+
+// checkPrivateKey normalizes the private key and checks that it is a supported type
+func checkPrivateKey(key crypto.PrivateKey) (crypto.PrivateKey, error) {
+	switch key := key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return key, nil
+	case *ed25519.PrivateKey:
+		return *key, nil
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+}
+
+// checkPublicKey normalizes the public key and checks that it is a supported type
+func checkPublicKey(key crypto.PublicKey) (crypto.PublicKey, error) {
+	switch key := key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	case *ed25519.PublicKey:
+		return *key, nil
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+}
+
+// encodeKey returns the der or the PEM block of the der
+func encodeKey(der []byte, blockType string, encoding KeyEncoding) []byte {
+	if encoding == KeyEncodingDer {
+		return der
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
@@ -0,0 +1,297 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WatchConfig defines the config for watching a key folder
+type WatchConfig struct {
+	// Interval is the polling interval
+	Interval time.Duration
+	// OnAdd is called after a key file was added or changed and its key became the active version
+	OnAdd func(keyName, keyID string)
+	// OnRemove is called after the versions loaded from a key file were removed from the keystore because the file disappeared
+	OnRemove func(keyName string)
+	// OnError is called when a key file could not be loaded. The keystore keeps the previous versions of the key.
+	// It is called with an empty key name when a poll of the whole folder failed
+	OnError func(keyName string, err error)
+}
+
+// DefaultWatchConfig defines the default key folder watch config
+var DefaultWatchConfig = WatchConfig{
+	Interval: 30 * time.Second,
+}
+
+// LoadKeysFromFolder Parses and Loads all the pem encoded private keys in a folder
+// (filename without extension is used as the key name. Ex: signing.pem -> "signing").
+// Hidden files and sub folders are skipped. If a key already exists with a different key,
+// the loaded key becomes its new active version. A file that can not be loaded does not keep
+// the other keys from loading, the first such error is returned after all files were tried.
+// Two files with the same key name fail before any key is loaded
+func (store *KeyStore) LoadKeysFromFolder(folderPath string) error {
+	files, err := listKeyFiles(folderPath)
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for keyName, path := range files {
+		if _, _, _, err := store.loadKeyFile(keyName, path); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// KeyFolderWatcher polls a key folder and keeps the keystore in sync with it
+type KeyFolderWatcher struct {
+	store      *KeyStore
+	folderPath string
+	config     WatchConfig
+	// files maps the key names loaded by the watcher to the checksum of their file
+	files map[string][sha256.Size]byte
+	// versions maps the key names loaded by the watcher to the ids of the versions it added
+	versions map[string][]string
+	// invalid maps the key names of files that failed to load to their checksum, so errors are reported once
+	invalid  map[string][sha256.Size]byte
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// WatchFolder loads the keys in the folder and watches it with the default config
+func (store *KeyStore) WatchFolder(folderPath string) (*KeyFolderWatcher, error) {
+	return store.WatchFolderWithConfig(folderPath, DefaultWatchConfig)
+}
+
+// WatchFolderWithConfig loads the keys in the folder like LoadKeysFromFolder and polls it for changes.
+// New and changed files are loaded as the active version of their key. When a file disappears the versions
+// loaded from it are removed, versions added in other ways are kept. A file that can not be loaded is reported
+// to OnError and does not keep the other keys from loading. Two files with the same key name fail the
+// initial load and skip the polls until resolved. Call Stop to end watching
+func (store *KeyStore) WatchFolderWithConfig(folderPath string, config WatchConfig) (*KeyFolderWatcher, error) {
+	if config.Interval <= 0 {
+		config.Interval = DefaultWatchConfig.Interval
+	}
+
+	watcher := &KeyFolderWatcher{
+		store:      store,
+		folderPath: folderPath,
+		config:     config,
+		files:      make(map[string][sha256.Size]byte),
+		versions:   make(map[string][]string),
+		invalid:    make(map[string][sha256.Size]byte),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if err := watcher.sync(); err != nil {
+		return nil, err
+	}
+
+	go watcher.run()
+	return watcher, nil
+}
+
+// Stop stops watching the folder and waits for a running poll to finish. The loaded keys stay in the keystore
+func (w *KeyFolderWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
+
+// run polls the folder until stopped
+func (w *KeyFolderWatcher) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			if err := w.sync(); err != nil && w.config.OnError != nil {
+				w.config.OnError("", err)
+			}
+		}
+	}
+}
+
+// sync loads new and changed key files and removes the keys whose files disappeared.
+// It only fails if the folder can not be read or two files have the same key name
+func (w *KeyFolderWatcher) sync() error {
+	files, err := listKeyFiles(w.folderPath)
+	if err != nil {
+		return err
+	}
+
+	for keyName, path := range files {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			w.onError(keyName, err)
+			continue
+		}
+		checksum := sha256.Sum256(data)
+		if previous, ok := w.files[keyName]; ok && previous == checksum {
+			continue
+		}
+		if previous, ok := w.invalid[keyName]; ok && previous == checksum {
+			continue
+		}
+
+		key, err := ParsePrivateKeyFromPemStr(string(data))
+		if err != nil {
+			w.invalid[keyName] = checksum
+			w.onError(keyName, err)
+			continue
+		}
+		keyID, changed, added, err := w.store.upsertKey(keyName, key)
+		if err != nil {
+			w.invalid[keyName] = checksum
+			w.onError(keyName, err)
+			continue
+		}
+		delete(w.invalid, keyName)
+		w.files[keyName] = checksum
+		if added {
+			w.versions[keyName] = append(w.versions[keyName], keyID)
+		}
+		if changed && w.config.OnAdd != nil {
+			w.config.OnAdd(keyName, keyID)
+		}
+	}
+
+	for keyName := range w.invalid {
+		if _, ok := files[keyName]; !ok {
+			delete(w.invalid, keyName)
+		}
+	}
+	for keyName := range w.files {
+		if _, ok := files[keyName]; ok {
+			continue
+		}
+		keyIDs := w.versions[keyName]
+		delete(w.files, keyName)
+		delete(w.versions, keyName)
+		if w.store.removeKeyVersions(keyName, keyIDs) && w.config.OnRemove != nil {
+			w.config.OnRemove(keyName)
+		}
+	}
+	return nil
+}
+
+// onError reports a key file error
+func (w *KeyFolderWatcher) onError(keyName string, err error) {
+	if w.config.OnError != nil {
+		w.config.OnError(keyName, err)
+	}
+}
+
+// loadKeyFile parses the key file and upserts it into the keystore
+func (store *KeyStore) loadKeyFile(keyName, path string) (keyID string, changed, added bool, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", false, false, err
+	}
+	key, err := ParsePrivateKeyFromPemStr(string(data))
+	if err != nil {
+		return "", false, false, err
+	}
+	return store.upsertKey(keyName, key)
+}
+
+// upsertKey makes the key the active version of the key name. It reports whether anything changed
+// and whether a new version was added
+func (store *KeyStore) upsertKey(keyName string, key Key) (keyID string, changed, added bool, err error) {
+	keyID, err = KeyID(key)
+	if err != nil {
+		return "", false, false, err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	// A key that was loaded before is reactivated instead of added twice
+	if version := store.version(keyName, keyID); version != nil {
+		if version.State == KeyStateActive {
+			return keyID, false, false, nil
+		}
+		store.activate(keyName, version)
+		return keyID, true, false, nil
+	}
+
+	store.keys[keyName] = append(store.keys[keyName], &KeyVersion{
+		ID:        keyID,
+		CreatedAt: time.Now().UTC(),
+		Key:       key,
+	})
+	store.activate(keyName, store.keys[keyName][len(store.keys[keyName])-1])
+	return keyID, true, true, nil
+}
+
+// removeKeyVersions removes the versions with the ids from the key. The key is removed once it has no versions left.
+// It reports whether any version was removed
+func (store *KeyStore) removeKeyVersions(keyName string, keyIDs []string) bool {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	versions := store.keys[keyName]
+	kept := versions[:0]
+	for _, version := range versions {
+		if !containsString(keyIDs, version.ID) {
+			kept = append(kept, version)
+		}
+	}
+	if len(kept) == 0 {
+		delete(store.keys, keyName)
+	} else {
+		store.keys[keyName] = kept
+	}
+	return len(kept) != len(versions)
+}
+
+// containsString reports whether the value is in the values
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// listKeyFiles maps the key names to the paths of the key files in the folder. Key names must be unique
+func listKeyFiles(folderPath string) (map[string]string, error) {
+	entries, err := ioutil.ReadDir(folderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if strings.HasPrefix(fileName, ".") {
+			continue
+		}
+		path := filepath.Join(folderPath, fileName)
+		// Follow symlinks as secret mounters usually link the files into the folder
+		fileInfo, err := os.Stat(path)
+		if err != nil || fileInfo.IsDir() {
+			continue
+		}
+		keyName := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+		if other, ok := files[keyName]; ok {
+			return nil, fmt.Errorf("key files %s and %s have the same key name %q", filepath.Base(other), fileName, keyName)
+		}
+		files[keyName] = path
+	}
+	return files, nil
+}
@@ -1,43 +1,410 @@
 package crypto
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
 )
 
-// KeyStore is a as the name says a keystore which stores multiple privatekeys
+// KeyState defines the lifecycle state of a key version
+type KeyState int
+
+const (
+	// KeyStateActive is used for signing and encryption. There is at most one active version per key name
+	KeyStateActive KeyState = iota + 1
+	// KeyStateDecryptOnly is only used to verify signatures and decrypt existing data
+	KeyStateDecryptOnly
+	// KeyStateRetired is kept in the keystore but no longer used for any operation
+	KeyStateRetired
+)
+
+// String returns the name of the key state
+func (s KeyState) String() string {
+	switch s {
+	case KeyStateActive:
+		return "active"
+	case KeyStateDecryptOnly:
+		return "decrypt-only"
+	case KeyStateRetired:
+		return "retired"
+	default:
+		return fmt.Sprintf("KeyState(%d)", int(s))
+	}
+}
+
+// ParseKeyState parses the name of a key state as returned by KeyState.String
+func ParseKeyState(name string) (KeyState, error) {
+	for state := KeyStateActive; state <= KeyStateRetired; state++ {
+		if state.String() == name {
+			return state, nil
+		}
+	}
+	return 0, ErrInvalidKeyState
+}
+
+var (
+	// ErrKeyNotFound is returned when a key is not in the keystore
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrKeyExists is returned when adding a key name or key version that is already in the keystore
+	ErrKeyExists = errors.New("key already exists")
+	// ErrNoActiveKey is returned when signing or encrypting with a key name that has no active version
+	ErrNoActiveKey = errors.New("key has no active version")
+	// ErrInvalidKeyState is returned when setting an unknown key state
+	ErrInvalidKeyState = errors.New("invalid key state")
+	// ErrVerification is returned when a signature could not be verified by any usable key version
+	ErrVerification = errors.New("signature verification failed")
+)
+
+// KeyVersion is a single version of a named key
+type KeyVersion struct {
+	// ID identifies the version. It is derived from the public key
+	ID        string
+	CreatedAt time.Time
+	State     KeyState
+	Key       Key
+}
+
+// Usable reports whether the version may be used to verify signatures and decrypt data
+func (v *KeyVersion) Usable() bool {
+	return v.State == KeyStateActive || v.State == KeyStateDecryptOnly
+}
+
+// KeyStore is a as the name says a keystore which stores multiple privatekeys.
+// It holds rsa, ecdsa and ed25519 private keys as well as raw secrets.
+// Every key name holds a list of versions of which at most one is active.
+// It is safe for concurrent use
 type KeyStore struct {
-	keys map[string]*rsa.PrivateKey
+	mu   sync.RWMutex
+	keys map[string][]*KeyVersion
 }
 
-// AddKeyFromFile Adds a private rsa key to the keystore from the file
+// AddKeyFromFile Adds a pem encoded private key to the keystore from the file. The key name must not exist yet
 func (store *KeyStore) AddKeyFromFile(keyName, fileName string) error {
-	key, err := ParseRsaPrivateKeyFromFile(fileName)
+	privPEM, err := ioutil.ReadFile(fileName)
 	if err != nil {
 		return err
 	}
-	store.keys[keyName] = key
-	return nil
+	return store.AddKey(keyName, string(privPEM))
 }
 
-// AddKey Adds a private rsa key to the keystore
+// AddKey Adds a pem encoded rsa, ecdsa or ed25519 private key to the keystore. The key name must not exist yet
 func (store *KeyStore) AddKey(keyName, pem string) error {
-	key, err := ParseRsaPrivateKeyFromPemStr(pem)
+	key, err := ParsePrivateKeyFromPemStr(pem)
+	if err != nil {
+		return err
+	}
+	return store.addKey(keyName, key, false)
+}
+
+// AddPrivateKey Adds a private key to the keystore. See NewKey for the supported key types.
+// The key name must not exist yet
+func (store *KeyStore) AddPrivateKey(keyName string, privateKey crypto.PrivateKey) error {
+	key, err := NewKey(privateKey)
+	if err != nil {
+		return err
+	}
+	return store.addKey(keyName, key, false)
+}
+
+// AddSecretKey Adds a raw secret for HMAC and symmetric encryption to the keystore. The key name must not exist yet
+func (store *KeyStore) AddSecretKey(keyName string, secret []byte) error {
+	return store.AddPrivateKey(keyName, secret)
+}
+
+// RotateKey Adds a new active version of the key from its pem encoding. The previously active version
+// becomes decrypt only, so existing signatures and data can still be verified and decrypted
+func (store *KeyStore) RotateKey(keyName, pem string) error {
+	key, err := ParsePrivateKeyFromPemStr(pem)
+	if err != nil {
+		return err
+	}
+	return store.addKey(keyName, key, true)
+}
+
+// RotatePrivateKey Adds a new active version of the key like RotateKey. See NewKey for the supported key types
+func (store *KeyStore) RotatePrivateKey(keyName string, privateKey crypto.PrivateKey) error {
+	key, err := NewKey(privateKey)
+	if err != nil {
+		return err
+	}
+	return store.addKey(keyName, key, true)
+}
+
+// addKey adds the key as the new active version
+func (store *KeyStore) addKey(keyName string, key Key, rotate bool) error {
+	id, err := KeyID(key)
 	if err != nil {
 		return err
 	}
-	store.keys[keyName] = key
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	versions := store.keys[keyName]
+	if len(versions) > 0 && !rotate {
+		return ErrKeyExists
+	}
+	for _, version := range versions {
+		if version.ID == id {
+			return ErrKeyExists
+		}
+	}
+
+	version := &KeyVersion{
+		ID:        id,
+		CreatedAt: time.Now().UTC(),
+		Key:       key,
+	}
+	store.keys[keyName] = append(versions, version)
+	store.activate(keyName, version)
+	return nil
+}
+
+// activate makes the version the active one. The previously active version becomes decrypt only.
+// The caller must hold the lock
+func (store *KeyStore) activate(keyName string, version *KeyVersion) {
+	for _, v := range store.keys[keyName] {
+		if v.State == KeyStateActive {
+			v.State = KeyStateDecryptOnly
+		}
+	}
+	version.State = KeyStateActive
+}
+
+// SetKeyState Changes the state of a key version. Activating a version makes the previously active one decrypt only
+func (store *KeyStore) SetKeyState(keyName, keyID string, state KeyState) error {
+	if state < KeyStateActive || state > KeyStateRetired {
+		return ErrInvalidKeyState
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	version := store.version(keyName, keyID)
+	if version == nil {
+		return ErrKeyNotFound
+	}
+	if state == KeyStateActive {
+		store.activate(keyName, version)
+		return nil
+	}
+	version.State = state
 	return nil
 }
 
-// Key Gets the private key for the given identifier
+// RemoveKey Removes all versions of the key. It reports whether the key existed
+func (store *KeyStore) RemoveKey(keyName string) bool {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	_, ok := store.keys[keyName]
+	delete(store.keys, keyName)
+	return ok
+}
+
+// KeyNames Gets the names of all keys in the keystore, sorted
+func (store *KeyStore) KeyNames() []string {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return store.keyNames()
+}
+
+// keyNames returns the sorted key names. The caller must hold the lock
+func (store *KeyStore) keyNames() []string {
+	names := make([]string, 0, len(store.keys))
+	for name := range store.keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Key Gets the active private rsa key for the given identifier. Same as RsaKey
 func (store *KeyStore) Key(keyName string) (*rsa.PrivateKey, bool) {
-	val, isOk := store.keys[keyName]
-	return val, isOk
+	return store.RsaKey(keyName)
+}
+
+// ActiveKey Gets the active key for the given identifier regardless of its type
+func (store *KeyStore) ActiveKey(keyName string) (Key, bool) {
+	version, ok := store.activeKeyVersion(keyName)
+	if !ok {
+		return nil, false
+	}
+	return version.Key, true
+}
+
+// RsaKey Gets the active key if it is a rsa private key
+func (store *KeyStore) RsaKey(keyName string) (*rsa.PrivateKey, bool) {
+	key, ok := store.typedKey(keyName, KeyTypeRsa)
+	if !ok {
+		return nil, false
+	}
+	return key.(*rsa.PrivateKey), true
+}
+
+// EcdsaKey Gets the active key if it is an ecdsa private key
+func (store *KeyStore) EcdsaKey(keyName string) (*ecdsa.PrivateKey, bool) {
+	key, ok := store.typedKey(keyName, KeyTypeEcdsa)
+	if !ok {
+		return nil, false
+	}
+	return key.(*ecdsa.PrivateKey), true
+}
+
+// Ed25519Key Gets the active key if it is an ed25519 private key
+func (store *KeyStore) Ed25519Key(keyName string) (ed25519.PrivateKey, bool) {
+	key, ok := store.typedKey(keyName, KeyTypeEd25519)
+	if !ok {
+		return nil, false
+	}
+	return key.(ed25519.PrivateKey), true
+}
+
+// SecretKey Gets a copy of the active key if it is a raw secret
+func (store *KeyStore) SecretKey(keyName string) ([]byte, bool) {
+	key, ok := store.typedKey(keyName, KeyTypeSecret)
+	if !ok {
+		return nil, false
+	}
+	return key.([]byte), true
+}
+
+// typedKey returns the underlying private key of the active version if it has the given type
+func (store *KeyStore) typedKey(keyName string, keyType KeyType) (crypto.PrivateKey, bool) {
+	version, ok := store.activeKeyVersion(keyName)
+	if !ok || version.Key.Type() != keyType {
+		return nil, false
+	}
+	return version.Key.Private(), true
+}
+
+// KeyVersion Gets the key version with the given id
+func (store *KeyStore) KeyVersion(keyName, keyID string) (KeyVersion, bool) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	version := store.version(keyName, keyID)
+	if version == nil {
+		return KeyVersion{}, false
+	}
+	return *version, true
+}
+
+// ActiveKeyVersion Gets the active version of the key
+func (store *KeyStore) ActiveKeyVersion(keyName string) (KeyVersion, bool) {
+	return store.activeKeyVersion(keyName)
+}
+
+// FindKeyVersion Gets the key version with the given id from any key name
+func (store *KeyStore) FindKeyVersion(keyID string) (keyName string, version KeyVersion, ok bool) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	for _, name := range store.keyNames() {
+		if v := store.version(name, keyID); v != nil {
+			return name, *v, true
+		}
+	}
+	return "", KeyVersion{}, false
+}
+
+// KeyVersions Gets all versions of the key, oldest first
+func (store *KeyStore) KeyVersions(keyName string) []KeyVersion {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	versions := make([]KeyVersion, 0, len(store.keys[keyName]))
+	for _, version := range store.keys[keyName] {
+		versions = append(versions, *version)
+	}
+	return versions
+}
+
+// Sign Signs the message with the active version of the key. The id of the version is returned with the signature.
+// The signature scheme depends on the key type: PKCS#1 v1.5 with SHA-512 for rsa, ASN.1 ecdsa with the hash
+// matching the curve, ed25519 and HMAC-SHA512 for secrets
+func (store *KeyStore) Sign(keyName string, msg []byte) (keyID string, signature []byte, err error) {
+	version, ok := store.activeKeyVersion(keyName)
+	if !ok {
+		return "", nil, ErrNoActiveKey
+	}
+	signature, err = version.Key.Sign(msg)
+	if err != nil {
+		return "", nil, err
+	}
+	return version.ID, signature, nil
+}
+
+// Verify Verifies the signature with the given version of the key. If keyID is empty every
+// version that is not retired is tried
+func (store *KeyStore) Verify(keyName, keyID string, msg, signature []byte) error {
+	for _, version := range store.usableVersions(keyName, keyID) {
+		if version.Key.Verify(msg, signature) == nil {
+			return nil
+		}
+	}
+	return ErrVerification
+}
+
+// activeKeyVersion returns a copy of the active version of the key
+func (store *KeyStore) activeKeyVersion(keyName string) (KeyVersion, bool) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	version := store.activeVersion(keyName)
+	if version == nil {
+		return KeyVersion{}, false
+	}
+	return *version, true
+}
+
+// activeVersion returns the active version of the key or nil. The caller must hold the lock
+func (store *KeyStore) activeVersion(keyName string) *KeyVersion {
+	for _, version := range store.keys[keyName] {
+		if version.State == KeyStateActive {
+			return version
+		}
+	}
+	return nil
+}
+
+// version returns the version of the key with the given id or nil. The caller must hold the lock
+func (store *KeyStore) version(keyName, keyID string) *KeyVersion {
+	for _, version := range store.keys[keyName] {
+		if version.ID == keyID {
+			return version
+		}
+	}
+	return nil
+}
+
+// usableVersions returns copies of the versions that are not retired, newest first.
+// If keyID is given only that version is returned
+func (store *KeyStore) usableVersions(keyName, keyID string) []KeyVersion {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	versions := store.keys[keyName]
+	usable := make([]KeyVersion, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].Usable() && (keyID == "" || versions[i].ID == keyID) {
+			usable = append(usable, *versions[i])
+		}
+	}
+	return usable
 }
 
 // NewKeyStore Creates a new keystore
 func NewKeyStore() *KeyStore {
 	return &KeyStore{
-		keys: make(map[string]*rsa.PrivateKey),
+		keys: make(map[string][]*KeyVersion),
 	}
 }
@@ -0,0 +1,421 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// The keystore file holds every key of a KeyStore encrypted under a passphrase. The format is:
+// magic | version | kdf | kdfParams | salt | payload
+// The payload is the json encoded keystore encrypted by EncryptUsingSymmKeyWithConfig with the key
+// derived from the passphrase. Everything before the payload is authenticated as associated data
+
+// keyStoreFileMagic identifies keystore files
+var keyStoreFileMagic = []byte("SKKS")
+
+// keyStoreFileVersion is the current version of the keystore file format
+const keyStoreFileVersion byte = 1
+
+// keyStoreFileSaltSize is the size of the random passphrase salt
+const keyStoreFileSaltSize = 16
+
+// The KDF parameters are read from the file before the header can be authenticated,
+// so they are bounded to keep a crafted file from exhausting memory or cpu
+const (
+	// maxKDFMemory is the most memory in bytes a KDF may use
+	maxKDFMemory = 1 << 30
+	// maxArgon2Time is the most Argon2id passes
+	maxArgon2Time = 16
+	// maxScryptLogN is the largest base 2 logarithm of the scrypt cost parameter N
+	maxScryptLogN = 20
+	// maxScryptRP is the largest product of the scrypt block size and parallelism
+	maxScryptRP = 256
+)
+
+// PassphraseKDF defines the key derivation function used to derive a key from a passphrase
+type PassphraseKDF byte
+
+const (
+	// Argon2id derives the key using Argon2id
+	Argon2id PassphraseKDF = iota + 1
+	// Scrypt derives the key using scrypt
+	Scrypt
+)
+
+var (
+	// ErrInvalidKeyStoreFile is returned when a keystore file is malformed
+	ErrInvalidKeyStoreFile = errors.New("invalid keystore file")
+	// ErrEmptyPassphrase is returned when encrypting a keystore without a passphrase
+	ErrEmptyPassphrase = errors.New("empty passphrase")
+)
+
+// KeyStoreFileConfig defines the config for encrypting a keystore file
+type KeyStoreFileConfig struct {
+	// KDF is the passphrase key derivation function
+	KDF PassphraseKDF
+	// Argon2Time is the number of Argon2id passes
+	Argon2Time uint32
+	// Argon2Memory is the Argon2id memory in KiB
+	Argon2Memory uint32
+	// Argon2Threads is the Argon2id parallelism
+	Argon2Threads uint8
+	// ScryptLogN is the base 2 logarithm of the scrypt cost parameter N
+	ScryptLogN uint8
+	// ScryptR is the scrypt block size
+	ScryptR uint32
+	// ScryptP is the scrypt parallelism
+	ScryptP uint32
+}
+
+// DefaultKeyStoreFileConfig defines the default keystore file config
+var DefaultKeyStoreFileConfig = KeyStoreFileConfig{
+	KDF:           Argon2id,
+	Argon2Time:    3,
+	Argon2Memory:  64 * 1024,
+	Argon2Threads: 4,
+	ScryptLogN:    15,
+	ScryptR:       8,
+	ScryptP:       1,
+}
+
+// withDefaults fills the unset fields of the config from the default config
+func (config KeyStoreFileConfig) withDefaults() KeyStoreFileConfig {
+	if config.KDF == 0 {
+		config.KDF = DefaultKeyStoreFileConfig.KDF
+	}
+	if config.Argon2Time == 0 {
+		config.Argon2Time = DefaultKeyStoreFileConfig.Argon2Time
+	}
+	if config.Argon2Memory == 0 {
+		config.Argon2Memory = DefaultKeyStoreFileConfig.Argon2Memory
+	}
+	if config.Argon2Threads == 0 {
+		config.Argon2Threads = DefaultKeyStoreFileConfig.Argon2Threads
+	}
+	if config.ScryptLogN == 0 {
+		config.ScryptLogN = DefaultKeyStoreFileConfig.ScryptLogN
+	}
+	if config.ScryptR == 0 {
+		config.ScryptR = DefaultKeyStoreFileConfig.ScryptR
+	}
+	if config.ScryptP == 0 {
+		config.ScryptP = DefaultKeyStoreFileConfig.ScryptP
+	}
+	return config
+}
+
+// keyStoreFileContent is the json encoded payload of the keystore file
+type keyStoreFileContent struct {
+	Keys []keyStoreFileKey `json:"keys"`
+}
+
+// keyStoreFileKey is a named key in the keystore file
+type keyStoreFileKey struct {
+	Name     string                   `json:"name"`
+	Versions []keyStoreFileKeyVersion `json:"versions"`
+}
+
+// keyStoreFileKeyVersion is a key version in the keystore file. The key is the PKCS#8 DER encoded
+// private key or the raw secret
+type keyStoreFileKeyVersion struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	State     string    `json:"state"`
+	Type      KeyType   `json:"type"`
+	Key       []byte    `json:"key"`
+}
+
+// SaveToFile Encrypts all keys under the passphrase using the default config and writes them to the file
+func (store *KeyStore) SaveToFile(fileName string, passphrase []byte) error {
+	return store.SaveToFileWithConfig(fileName, passphrase, DefaultKeyStoreFileConfig)
+}
+
+// SaveToFileWithConfig Encrypts all keys under the passphrase and writes them to the file.
+// Unset config fields are taken from DefaultKeyStoreFileConfig. The file is replaced atomically and is only readable by the owner
+func (store *KeyStore) SaveToFileWithConfig(fileName string, passphrase []byte, config KeyStoreFileConfig) error {
+	data, err := store.MarshalWithConfig(passphrase, config)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(fileName, data, 0600)
+}
+
+// LoadKeyStoreFromFile Loads a keystore from a file written by SaveToFile
+func LoadKeyStoreFromFile(fileName string, passphrase []byte) (*KeyStore, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalKeyStore(data, passphrase)
+}
+
+// Marshal Encrypts all keys under the passphrase using the default config
+func (store *KeyStore) Marshal(passphrase []byte) ([]byte, error) {
+	return store.MarshalWithConfig(passphrase, DefaultKeyStoreFileConfig)
+}
+
+// MarshalWithConfig Encrypts all keys under the passphrase into the keystore file format.
+// Unset config fields are taken from DefaultKeyStoreFileConfig
+func (store *KeyStore) MarshalWithConfig(passphrase []byte, config KeyStoreFileConfig) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, ErrEmptyPassphrase
+	}
+	config = config.withDefaults()
+
+	content, err := store.fileContent()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+
+	header := bytes.NewBuffer(nil)
+	header.Write(keyStoreFileMagic)
+	header.WriteByte(keyStoreFileVersion)
+	header.WriteByte(byte(config.KDF))
+	switch config.KDF {
+	case Argon2id:
+		binary.Write(header, binary.BigEndian, config.Argon2Time)
+		binary.Write(header, binary.BigEndian, config.Argon2Memory)
+		header.WriteByte(config.Argon2Threads)
+	case Scrypt:
+		header.WriteByte(config.ScryptLogN)
+		binary.Write(header, binary.BigEndian, config.ScryptR)
+		binary.Write(header, binary.BigEndian, config.ScryptP)
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	salt := make([]byte, keyStoreFileSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	header.Write(salt)
+
+	key, err := deriveKeyFromPassphrase(passphrase, salt, config)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := EncryptUsingSymmKeyWithConfig(plaintext, key, SymmKeyConfig{
+		Algorithm:      DefaultSymmKeyConfig.Algorithm,
+		AssociatedData: header.Bytes(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(header.Bytes(), payload...), nil
+}
+
+// UnmarshalKeyStore Decrypts a keystore encrypted by Marshal
+func UnmarshalKeyStore(data, passphrase []byte) (*KeyStore, error) {
+	reader := bytes.NewReader(data)
+
+	magic := make([]byte, len(keyStoreFileMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil || !bytes.Equal(magic, keyStoreFileMagic) {
+		return nil, ErrInvalidKeyStoreFile
+	}
+	version, err := reader.ReadByte()
+	if err != nil {
+		return nil, ErrInvalidKeyStoreFile
+	}
+	if version != keyStoreFileVersion {
+		return nil, ErrUnsupportedVersion
+	}
+	kdf, err := reader.ReadByte()
+	if err != nil {
+		return nil, ErrInvalidKeyStoreFile
+	}
+
+	config := KeyStoreFileConfig{KDF: PassphraseKDF(kdf)}
+	switch config.KDF {
+	case Argon2id:
+		err = readBigEndian(reader, &config.Argon2Time, &config.Argon2Memory, &config.Argon2Threads)
+	case Scrypt:
+		err = readBigEndian(reader, &config.ScryptLogN, &config.ScryptR, &config.ScryptP)
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+	if err != nil {
+		return nil, ErrInvalidKeyStoreFile
+	}
+
+	salt := make([]byte, keyStoreFileSaltSize)
+	if _, err := io.ReadFull(reader, salt); err != nil {
+		return nil, ErrInvalidKeyStoreFile
+	}
+	headerSize := len(data) - reader.Len()
+	header, payload := data[:headerSize], data[headerSize:]
+
+	key, err := deriveKeyFromPassphrase(passphrase, salt, config)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := DecryptUsingSymmKeyWithConfig(payload, key, SymmKeyConfig{AssociatedData: header})
+	if err != nil {
+		return nil, err
+	}
+
+	var content keyStoreFileContent
+	if err := json.Unmarshal(plaintext, &content); err != nil {
+		return nil, err
+	}
+	return keyStoreFromFileContent(&content)
+}
+
+// fileContent collects all keys of the store for serialization
+func (store *KeyStore) fileContent() (*keyStoreFileContent, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	content := &keyStoreFileContent{Keys: make([]keyStoreFileKey, 0, len(store.keys))}
+	for _, keyName := range store.keyNames() {
+		fileKey := keyStoreFileKey{Name: keyName}
+		for _, version := range store.keys[keyName] {
+			var der []byte
+			if version.Key.Type() == KeyTypeSecret {
+				der = version.Key.Private().([]byte)
+			} else {
+				var err error
+				der, err = x509.MarshalPKCS8PrivateKey(version.Key.Private())
+				if err != nil {
+					return nil, err
+				}
+			}
+			fileKey.Versions = append(fileKey.Versions, keyStoreFileKeyVersion{
+				ID:        version.ID,
+				CreatedAt: version.CreatedAt,
+				State:     version.State.String(),
+				Type:      version.Key.Type(),
+				Key:       der,
+			})
+		}
+		content.Keys = append(content.Keys, fileKey)
+	}
+	return content, nil
+}
+
+// keyStoreFromFileContent rebuilds a keystore from its serialized form
+func keyStoreFromFileContent(content *keyStoreFileContent) (*KeyStore, error) {
+	store := NewKeyStore()
+	for _, fileKey := range content.Keys {
+		active := 0
+		for _, fileVersion := range fileKey.Versions {
+			var privateKey interface{} = fileVersion.Key
+			if fileVersion.Type != KeyTypeSecret {
+				var err error
+				privateKey, err = x509.ParsePKCS8PrivateKey(fileVersion.Key)
+				if err != nil {
+					return nil, err
+				}
+			}
+			key, err := NewKey(privateKey)
+			if err != nil {
+				return nil, err
+			}
+			if key.Type() != fileVersion.Type {
+				return nil, ErrInvalidKeyStoreFile
+			}
+			keyID, err := KeyID(key)
+			if err != nil {
+				return nil, err
+			}
+			if keyID != fileVersion.ID {
+				return nil, ErrInvalidKeyStoreFile
+			}
+			state, err := ParseKeyState(fileVersion.State)
+			if err != nil {
+				return nil, err
+			}
+			if state == KeyStateActive {
+				// There is at most one active version per key name
+				if active++; active > 1 {
+					return nil, ErrInvalidKeyStoreFile
+				}
+			}
+			store.keys[fileKey.Name] = append(store.keys[fileKey.Name], &KeyVersion{
+				ID:        fileVersion.ID,
+				CreatedAt: fileVersion.CreatedAt,
+				State:     state,
+				Key:       key,
+			})
+		}
+	}
+	return store, nil
+}
+
+// deriveKeyFromPassphrase derives the file encryption key from the passphrase
+func deriveKeyFromPassphrase(passphrase, salt []byte, config KeyStoreFileConfig) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, ErrEmptyPassphrase
+	}
+	switch config.KDF {
+	case Argon2id:
+		if config.Argon2Time == 0 || config.Argon2Memory == 0 || config.Argon2Threads == 0 ||
+			config.Argon2Time > maxArgon2Time || uint64(config.Argon2Memory)*1024 > maxKDFMemory {
+			return nil, ErrInvalidKeyStoreFile
+		}
+		return argon2.IDKey(passphrase, salt, config.Argon2Time, config.Argon2Memory, config.Argon2Threads, symmKeySize), nil
+	case Scrypt:
+		if config.ScryptLogN == 0 || config.ScryptLogN > maxScryptLogN || config.ScryptR == 0 || config.ScryptP == 0 ||
+			uint64(config.ScryptR)*uint64(config.ScryptP) > maxScryptRP ||
+			128*uint64(config.ScryptR)<<config.ScryptLogN > maxKDFMemory {
+			return nil, ErrInvalidKeyStoreFile
+		}
+		return scrypt.Key(passphrase, salt, 1<<config.ScryptLogN, int(config.ScryptR), int(config.ScryptP), symmKeySize)
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+}
+
+// readBigEndian reads the fixed size values in big endian order
+func readBigEndian(reader *bytes.Reader, values ...interface{}) error {
+	for _, value := range values {
+		if err := binary.Read(reader, binary.BigEndian, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic writes the data to a temporary file in the same folder and renames it over the file,
+// so readers never see a partially written file
+func writeFileAtomic(fileName string, data []byte, perm os.FileMode) error {
+	file, err := ioutil.TempFile(filepath.Dir(fileName), "."+filepath.Base(fileName)+".tmp")
+	if err != nil {
+		return err
+	}
+	tempName := file.Name()
+	defer os.Remove(tempName)
+
+	if err := file.Chmod(perm); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempName, fileName)
+}
@@ -0,0 +1,291 @@
+package otp
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/adityak368/swissknife/crypto"
+)
+
+// Algorithm defines the HMAC hash of the one time passwords
+type Algorithm string
+
+const (
+	// SHA1 is the default algorithm and the only one every authenticator app supports
+	SHA1 Algorithm = "SHA1"
+	// SHA256 is HMAC-SHA256
+	SHA256 Algorithm = "SHA256"
+	// SHA512 is HMAC-SHA512
+	SHA512 Algorithm = "SHA512"
+)
+
+// Errors returned when generating or validating one time passwords
+var (
+	ErrInvalidCode          = errors.New("invalid one time password")
+	ErrCodeReused           = errors.New("one time password was already used")
+	ErrInvalidDigits        = errors.New("digits must be between 6 and 10")
+	ErrUnsupportedAlgorithm = errors.New("unsupported otp algorithm")
+	ErrInvalidSecret        = errors.New("invalid otp secret")
+)
+
+// secretSize is the size of generated secrets. RFC 4226 recommends 160 bits
+const secretSize = 20
+
+// Config defines the one time password config
+type Config struct {
+	// Digits is the length of the codes, 6 to 10
+	Digits int
+	// Period is the lifetime of a TOTP code. It is rounded up to whole seconds
+	Period time.Duration
+	// Algorithm is the HMAC hash
+	Algorithm Algorithm
+	// Skew is the number of periods before and after the current one that are accepted for TOTP,
+	// or the number of counters after the expected one that are accepted for HOTP. Zero accepts only exact matches
+	Skew uint
+	// Now returns the current time. Defaults to time.Now
+	Now func() time.Time
+}
+
+// DefaultConfig defines the default one time password config. It works with every authenticator app
+var DefaultConfig = Config{
+	Digits:    6,
+	Period:    30 * time.Second,
+	Algorithm: SHA1,
+	Skew:      1,
+	Now:       time.Now,
+}
+
+// withDefaults fills the unset fields of the config from the default config
+func (config Config) withDefaults() Config {
+	if config.Digits == 0 {
+		config.Digits = DefaultConfig.Digits
+	}
+	if config.Period <= 0 {
+		config.Period = DefaultConfig.Period
+	}
+	// Time steps and provisioning URIs count whole seconds
+	if rest := config.Period % time.Second; rest != 0 {
+		config.Period += time.Second - rest
+	}
+	if config.Algorithm == "" {
+		config.Algorithm = DefaultConfig.Algorithm
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return config
+}
+
+// GenerateSecret returns a random secret
+func GenerateSecret() ([]byte, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// EncodeSecret encodes the secret as unpadded base32, the format authenticator apps expect
+func EncodeSecret(secret []byte) string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
+}
+
+// DecodeSecret decodes a base32 secret. Spaces, padding and lowercase letters are accepted
+func DecodeSecret(encoded string) ([]byte, error) {
+	encoded = strings.ToUpper(strings.ReplaceAll(encoded, " ", ""))
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil || len(secret) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return secret, nil
+}
+
+// HOTP returns the RFC 4226 code for the counter
+func HOTP(secret []byte, counter uint64, config Config) (string, error) {
+	config = config.withDefaults()
+	if config.Digits < 6 || config.Digits > 10 {
+		return "", ErrInvalidDigits
+	}
+	hashFunc, err := config.Algorithm.hash()
+	if err != nil {
+		return "", err
+	}
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+	sum := crypto.HMAC(msg, secret, hashFunc)
+
+	// Dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := uint64(binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff)
+	mod := uint64(1)
+	for i := 0; i < config.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", config.Digits, value%mod), nil
+}
+
+// ValidateHOTP checks the code against the expected counter and the next Skew counters.
+// It returns the counter to store for the next validation, which is the matched counter plus one
+func ValidateHOTP(code string, secret []byte, counter uint64, config Config) (uint64, error) {
+	config = config.withDefaults()
+	for i := uint64(0); i <= uint64(config.Skew); i++ {
+		ok, err := matches(code, secret, counter+i, config)
+		if err != nil {
+			return counter, err
+		}
+		if ok {
+			return counter + i + 1, nil
+		}
+	}
+	return counter, ErrInvalidCode
+}
+
+// TOTP returns the RFC 6238 code for the current time
+func TOTP(secret []byte, config Config) (string, error) {
+	config = config.withDefaults()
+	return TOTPAt(secret, config.Now(), config)
+}
+
+// TOTPAt returns the RFC 6238 code for the time
+func TOTPAt(secret []byte, t time.Time, config Config) (string, error) {
+	config = config.withDefaults()
+	return HOTP(secret, timeStep(t, config.Period), config)
+}
+
+// ValidateTOTP checks the code against the current period and Skew periods before and after it.
+// It returns the matched time step, which can be stored to reject the code if it is used again
+func ValidateTOTP(code string, secret []byte, config Config) (uint64, error) {
+	config = config.withDefaults()
+	current := timeStep(config.Now(), config.Period)
+	skew := uint64(config.Skew)
+
+	var matched uint64
+	found := false
+	// Every step in the window is checked, so the time taken does not depend on which one matched
+	for step := saturatingSub(current, skew); step <= current+skew; step++ {
+		ok, err := matches(code, secret, step, config)
+		if err != nil {
+			return 0, err
+		}
+		if ok && !found {
+			matched, found = step, true
+		}
+	}
+	if !found {
+		return 0, ErrInvalidCode
+	}
+	return matched, nil
+}
+
+// ReplayStore remembers the last used time step per account to reject reused codes
+type ReplayStore interface {
+	// UseStep records the step for the account. It returns false if the step is not newer than the last recorded step
+	UseStep(accountID string, step uint64) (bool, error)
+}
+
+// ValidateTOTPOnce validates the code like ValidateTOTP and rejects codes of a time step that was already
+// used by the account, so an observed code can not be replayed within its lifetime
+func ValidateTOTPOnce(accountID, code string, secret []byte, store ReplayStore, config Config) error {
+	step, err := ValidateTOTP(code, secret, config)
+	if err != nil {
+		return err
+	}
+	ok, err := store.UseStep(accountID, step)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCodeReused
+	}
+	return nil
+}
+
+// ProvisioningURI returns the otpauth:// URI of a TOTP secret. Show it as a QR code to add the account to an authenticator app
+func ProvisioningURI(issuer, accountName string, secret []byte, config Config) string {
+	config = config.withDefaults()
+	params := provisioningParams(issuer, secret, config)
+	params.Set("period", fmt.Sprint(int(config.Period/time.Second)))
+	return provisioningURI("totp", issuer, accountName, params)
+}
+
+// HOTPProvisioningURI returns the otpauth:// URI of a HOTP secret
+func HOTPProvisioningURI(issuer, accountName string, secret []byte, counter uint64, config Config) string {
+	config = config.withDefaults()
+	params := provisioningParams(issuer, secret, config)
+	params.Set("counter", fmt.Sprint(counter))
+	return provisioningURI("hotp", issuer, accountName, params)
+}
+
+// provisioningParams returns the query parameters shared by TOTP and HOTP URIs
+func provisioningParams(issuer string, secret []byte, config Config) url.Values {
+	params := url.Values{}
+	params.Set("secret", EncodeSecret(secret))
+	if issuer != "" {
+		params.Set("issuer", issuer)
+	}
+	params.Set("algorithm", string(config.Algorithm))
+	params.Set("digits", fmt.Sprint(config.Digits))
+	return params
+}
+
+// provisioningURI builds the otpauth:// URI with the label issuer:accountName
+func provisioningURI(otpType, issuer, accountName string, params url.Values) string {
+	label := url.PathEscape(accountName)
+	if issuer != "" {
+		label = url.PathEscape(issuer) + ":" + label
+	}
+	// Authenticator apps expect %20 instead of + for spaces
+	return "otpauth://" + otpType + "/" + label + "?" + strings.ReplaceAll(params.Encode(), "+", "%20")
+}
+
+// matches reports whether the code is the code of the counter
+func matches(code string, secret []byte, counter uint64, config Config) (bool, error) {
+	expected, err := HOTP(secret, counter, config)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1, nil
+}
+
+// hash returns the hash function of the algorithm
+func (alg Algorithm) hash() (func() hash.Hash, error) {
+	switch alg {
+	case SHA1:
+		return sha1.New, nil
+	case SHA256:
+		return sha256.New, nil
+	case SHA512:
+		return sha512.New, nil
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+}
+
+// timeStep returns the number of periods since the unix epoch
+func timeStep(t time.Time, period time.Duration) uint64 {
+	seconds := t.Unix()
+	if seconds < 0 {
+		return 0
+	}
+	return uint64(seconds) / uint64(period/time.Second)
+}
+
+// saturatingSub returns a-b or zero
+func saturatingSub(a, b uint64) uint64 {
+	if b > a {
+		return 0
+	}
+	return a - b
+}
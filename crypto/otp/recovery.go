@@ -0,0 +1,80 @@
+package otp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+)
+
+// recoveryAlphabet is the alphabet of recovery codes. It has no letters that are easily confused with digits
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// recoveryCodeLength is the number of characters of a recovery code, about 79 bits
+const recoveryCodeLength = 16
+
+// GenerateRecoveryCodes returns n single use recovery codes formatted as xxxx-xxxx-xxxx-xxxx.
+// Show them to the user once and store only their hashes
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		var b strings.Builder
+		for j := 0; j < recoveryCodeLength; j++ {
+			if j > 0 && j%4 == 0 {
+				b.WriteByte('-')
+			}
+			c, err := randomIndex(len(recoveryAlphabet))
+			if err != nil {
+				return nil, err
+			}
+			b.WriteByte(recoveryAlphabet[c])
+		}
+		codes[i] = b.String()
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode returns the hash of a recovery code to store. Recovery codes have enough entropy
+// that a fast hash is safe, so checking a code against all stored hashes stays cheap
+func HashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyRecoveryCode returns the index of the hash matching the code. Remove the hash at the index after a
+// successful login, so the code can only be used once. Case and separators of the code are ignored
+func VerifyRecoveryCode(code string, hashes []string) (int, bool) {
+	hash := []byte(HashRecoveryCode(code))
+	index := -1
+	for i, stored := range hashes {
+		if subtle.ConstantTimeCompare(hash, []byte(stored)) == 1 && index < 0 {
+			index = i
+		}
+	}
+	return index, index >= 0
+}
+
+// normalizeRecoveryCode removes separators and spaces and lowercases the code
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+}
+
+// randomIndex returns a uniformly distributed random number below n
+func randomIndex(n int) (int, error) {
+	limit := 256 - 256%n
+	b := make([]byte, 1)
+	for {
+		if _, err := rand.Read(b); err != nil {
+			return 0, err
+		}
+		if int(b[0]) < limit {
+			return int(b[0]) % n, nil
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package otp
+
+import "sync"
+
+// MemoryReplayStore is a ReplayStore for a single instance. Use a shared store, Ex: a column next to the secret,
+// when running multiple instances
+type MemoryReplayStore struct {
+	mu    sync.Mutex
+	steps map[string]uint64
+}
+
+// NewMemoryReplayStore Creates a new in memory ReplayStore
+func NewMemoryReplayStore() *MemoryReplayStore {
+	return &MemoryReplayStore{steps: map[string]uint64{}}
+}
+
+// UseStep implements the ReplayStore interface
+func (s *MemoryReplayStore) UseStep(accountID string, step uint64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if last, ok := s.steps[accountID]; ok && step <= last {
+		return false, nil
+	}
+	s.steps[accountID] = step
+	return true, nil
+}
@@ -0,0 +1,338 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Algorithm defines a password hashing algorithm
+type Algorithm string
+
+const (
+	// Argon2id is the recommended algorithm
+	Argon2id Algorithm = "argon2id"
+	// Bcrypt is supported for existing hashes and for environments that require it
+	Bcrypt Algorithm = "bcrypt"
+	// Scrypt is the scrypt algorithm
+	Scrypt Algorithm = "scrypt"
+)
+
+// Errors returned when hashing or verifying passwords
+var (
+	ErrInvalidHash          = errors.New("invalid password hash")
+	ErrUnsupportedAlgorithm = errors.New("unsupported password hash algorithm")
+	ErrPasswordTooLong      = errors.New("password is too long for bcrypt")
+)
+
+// bcryptMaxPasswordLength is the number of bytes bcrypt uses. Longer passwords would be truncated silently
+const bcryptMaxPasswordLength = 72
+
+// Config defines the password hashing config
+type Config struct {
+	// Algorithm is used for new hashes. Hashes of other algorithms still verify but need a rehash
+	Algorithm Algorithm
+	// Argon2Time is the number of passes over the memory
+	Argon2Time uint32
+	// Argon2Memory is the memory in KiB
+	Argon2Memory uint32
+	// Argon2Threads is the degree of parallelism
+	Argon2Threads uint8
+	// BcryptCost is the log2 of the number of rounds
+	BcryptCost int
+	// ScryptLogN is the log2 of the CPU/memory cost
+	ScryptLogN uint8
+	ScryptR    int
+	ScryptP    int
+	// SaltLength is the salt size in bytes for argon2id and scrypt
+	SaltLength uint32
+	// KeyLength is the hash size in bytes for argon2id and scrypt
+	KeyLength uint32
+}
+
+// DefaultConfig defines the default password hashing config
+var DefaultConfig = Config{
+	Algorithm:     Argon2id,
+	Argon2Time:    3,
+	Argon2Memory:  64 * 1024,
+	Argon2Threads: 4,
+	BcryptCost:    12,
+	ScryptLogN:    15,
+	ScryptR:       8,
+	ScryptP:       1,
+	SaltLength:    16,
+	KeyLength:     32,
+}
+
+// withDefaults fills the unset fields of the config from the default config
+func (config Config) withDefaults() Config {
+	if config.Algorithm == "" {
+		config.Algorithm = DefaultConfig.Algorithm
+	}
+	if config.Argon2Time == 0 {
+		config.Argon2Time = DefaultConfig.Argon2Time
+	}
+	if config.Argon2Memory == 0 {
+		config.Argon2Memory = DefaultConfig.Argon2Memory
+	}
+	if config.Argon2Threads == 0 {
+		config.Argon2Threads = DefaultConfig.Argon2Threads
+	}
+	if config.BcryptCost == 0 {
+		config.BcryptCost = DefaultConfig.BcryptCost
+	}
+	if config.ScryptLogN == 0 {
+		config.ScryptLogN = DefaultConfig.ScryptLogN
+	}
+	if config.ScryptR == 0 {
+		config.ScryptR = DefaultConfig.ScryptR
+	}
+	if config.ScryptP == 0 {
+		config.ScryptP = DefaultConfig.ScryptP
+	}
+	if config.SaltLength == 0 {
+		config.SaltLength = DefaultConfig.SaltLength
+	}
+	if config.KeyLength == 0 {
+		config.KeyLength = DefaultConfig.KeyLength
+	}
+	return config
+}
+
+// Hash hashes the password with the default config
+func Hash(password string) (string, error) {
+	return HashWithConfig(password, DefaultConfig)
+}
+
+// HashWithConfig hashes the password into a PHC string, Ex: $argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>.
+// Bcrypt hashes use the standard $2a$ format so they stay compatible with other bcrypt implementations
+func HashWithConfig(password string, config Config) (string, error) {
+	config = config.withDefaults()
+	switch config.Algorithm {
+	case Argon2id:
+		params := argon2Params{time: config.Argon2Time, memory: config.Argon2Memory, threads: config.Argon2Threads}
+		salt, err := newSalt(config.SaltLength)
+		if err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, config.KeyLength)
+		return params.encode(salt, key), nil
+	case Scrypt:
+		params := scryptParams{logN: config.ScryptLogN, r: config.ScryptR, p: config.ScryptP}
+		salt, err := newSalt(config.SaltLength)
+		if err != nil {
+			return "", err
+		}
+		key, err := scrypt.Key([]byte(password), salt, 1<<params.logN, params.r, params.p, int(config.KeyLength))
+		if err != nil {
+			return "", err
+		}
+		return params.encode(salt, key), nil
+	case Bcrypt:
+		if len(password) > bcryptMaxPasswordLength {
+			return "", ErrPasswordTooLong
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), config.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	default:
+		return "", ErrUnsupportedAlgorithm
+	}
+}
+
+// Verify reports whether the password matches the hash. The hashes are compared in constant time.
+// An error is returned only if the hash can not be parsed
+func Verify(password, hash string) (bool, error) {
+	switch algorithmOf(hash) {
+	case Argon2id:
+		params, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return false, err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+	case Scrypt:
+		params, salt, key, err := decodeScrypt(hash)
+		if err != nil {
+			return false, err
+		}
+		other, err := scrypt.Key([]byte(password), salt, 1<<params.logN, params.r, params.p, len(key))
+		if err != nil {
+			return false, ErrInvalidHash
+		}
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+	case Bcrypt:
+		if len(password) > bcryptMaxPasswordLength {
+			return false, nil
+		}
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		if err != nil {
+			return false, ErrInvalidHash
+		}
+		return true, nil
+	default:
+		return false, ErrUnsupportedAlgorithm
+	}
+}
+
+// NeedsRehash reports whether the hash was not created with the default config
+func NeedsRehash(hash string) bool {
+	return NeedsRehashWithConfig(hash, DefaultConfig)
+}
+
+// NeedsRehashWithConfig reports whether the hash uses another algorithm or other parameters than the config.
+// Hashes that can not be parsed always need a rehash
+func NeedsRehashWithConfig(hash string, config Config) bool {
+	config = config.withDefaults()
+	algorithm := algorithmOf(hash)
+	if algorithm != config.Algorithm {
+		return true
+	}
+	switch algorithm {
+	case Argon2id:
+		params, salt, key, err := decodeArgon2(hash)
+		return err != nil ||
+			params.time != config.Argon2Time ||
+			params.memory != config.Argon2Memory ||
+			params.threads != config.Argon2Threads ||
+			uint32(len(salt)) != config.SaltLength ||
+			uint32(len(key)) != config.KeyLength
+	case Scrypt:
+		params, salt, key, err := decodeScrypt(hash)
+		return err != nil ||
+			params.logN != config.ScryptLogN ||
+			params.r != config.ScryptR ||
+			params.p != config.ScryptP ||
+			uint32(len(salt)) != config.SaltLength ||
+			uint32(len(key)) != config.KeyLength
+	case Bcrypt:
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != config.BcryptCost
+	default:
+		return true
+	}
+}
+
+// VerifyAndRehash verifies the password and, if it matches and the hash is outdated, returns a new hash
+// created with the config. The new hash is empty if the stored hash is up to date.
+// Call it on login and store the new hash to upgrade hashes transparently
+func VerifyAndRehash(password, hash string, config Config) (ok bool, newHash string, err error) {
+	ok, err = Verify(password, hash)
+	if err != nil || !ok {
+		return false, "", err
+	}
+	if !NeedsRehashWithConfig(hash, config) {
+		return true, "", nil
+	}
+	newHash, err = HashWithConfig(password, config)
+	if err != nil {
+		return true, "", err
+	}
+	return true, newHash, nil
+}
+
+// algorithmOf returns the algorithm of a hash
+func algorithmOf(hash string) Algorithm {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return Argon2id
+	case strings.HasPrefix(hash, "$scrypt$"):
+		return Scrypt
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return Bcrypt
+	default:
+		return ""
+	}
+}
+
+// argon2Params defines the parameters of an argon2id hash
+type argon2Params struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+// encode returns the PHC string of the hash
+func (p argon2Params) encode(salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.memory, p.time, p.threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// decodeArgon2 parses an argon2id PHC string
+func decodeArgon2(hash string) (params argon2Params, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrInvalidHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrInvalidHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil ||
+		params.time == 0 || params.threads == 0 {
+		return params, nil, nil, ErrInvalidHash
+	}
+	salt, key, err = decodeSaltAndKey(parts[4], parts[5])
+	return params, salt, key, err
+}
+
+// scryptParams defines the parameters of a scrypt hash
+type scryptParams struct {
+	logN uint8
+	r    int
+	p    int
+}
+
+// encode returns the PHC string of the hash
+func (p scryptParams) encode(salt, key []byte) string {
+	return fmt.Sprintf("$scrypt$ln=%d,r=%d,p=%d$%s$%s", p.logN, p.r, p.p,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// decodeScrypt parses a scrypt PHC string
+func decodeScrypt(hash string) (params scryptParams, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 5 {
+		return params, nil, nil, ErrInvalidHash
+	}
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &params.logN, &params.r, &params.p); err != nil ||
+		params.logN == 0 || params.logN > 31 {
+		return params, nil, nil, ErrInvalidHash
+	}
+	salt, key, err = decodeSaltAndKey(parts[3], parts[4])
+	return params, salt, key, err
+}
+
+// decodeSaltAndKey decodes the base64 salt and key of a PHC string
+func decodeSaltAndKey(encodedSalt, encodedKey string) (salt, key []byte, err error) {
+	salt, err = base64.RawStdEncoding.DecodeString(encodedSalt)
+	if err != nil || len(salt) == 0 {
+		return nil, nil, ErrInvalidHash
+	}
+	key, err = base64.RawStdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) == 0 {
+		return nil, nil, ErrInvalidHash
+	}
+	return salt, key, nil
+}
+
+// newSalt returns a random salt
+func newSalt(size uint32) ([]byte, error) {
+	salt := make([]byte, size)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
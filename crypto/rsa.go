@@ -0,0 +1,184 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+)
+
+// RsaPadding defines the padding of rsa signatures
+type RsaPadding byte
+
+const (
+	// RsaPkcs1v15 signs using RSASSA-PKCS1-v1_5
+	RsaPkcs1v15 RsaPadding = iota + 1
+	// RsaPss signs using RSASSA-PSS
+	RsaPss
+)
+
+// RsaEncryptionMode defines how messages are encrypted with rsa keys
+type RsaEncryptionMode byte
+
+const (
+	// RsaOaep encrypts the message directly using RSA-OAEP. The message must be shorter than the key size minus 2*hashSize+2
+	RsaOaep RsaEncryptionMode = iota + 1
+	// RsaHybrid encrypts the message of any size with a random data key using the symmetric aead and the data key using RSA-OAEP.
+	// The format is: version | wrappedKeyLen | wrappedKey | ciphertext
+	RsaHybrid
+)
+
+// rsaHybridVersion is the current version of the hybrid format
+const rsaHybridVersion byte = 1
+
+// RsaOptions defines the options for rsa signatures and encryption
+type RsaOptions struct {
+	// Padding is used by SignRsa and VerifyRsa
+	Padding RsaPadding
+	// Hash is the signature hash and the OAEP hash
+	Hash crypto.Hash
+	// SaltLength is the RSA-PSS salt length. Zero uses rsa.PSSSaltLengthAuto
+	SaltLength int
+	// Encryption is used by EncryptRsa and DecryptRsa
+	Encryption RsaEncryptionMode
+	// Label is the OAEP label. In hybrid mode it is authenticated as associated data. It must match on decryption
+	Label []byte
+	// Algorithm is the aead used in hybrid mode
+	Algorithm AEADAlgorithm
+}
+
+// DefaultRsaOptions defines the default rsa options
+var DefaultRsaOptions = RsaOptions{
+	Padding:    RsaPss,
+	Hash:       crypto.SHA256,
+	SaltLength: rsa.PSSSaltLengthEqualsHash,
+	Encryption: RsaOaep,
+	Algorithm:  DefaultSymmKeyConfig.Algorithm,
+}
+
+// pkcs1v15Sha512Options are the options used by Sign and Verify
+var pkcs1v15Sha512Options = RsaOptions{Padding: RsaPkcs1v15, Hash: crypto.SHA512}
+
+// oaepSha256Options are the options used to wrap envelope data keys
+var oaepSha256Options = RsaOptions{Encryption: RsaOaep, Hash: crypto.SHA256}
+
+// withDefaults fills the unset options from the default options
+func (opts RsaOptions) withDefaults() RsaOptions {
+	if opts.Padding == 0 {
+		opts.Padding = DefaultRsaOptions.Padding
+	}
+	if opts.Hash == 0 {
+		opts.Hash = DefaultRsaOptions.Hash
+	}
+	if opts.Encryption == 0 {
+		opts.Encryption = DefaultRsaOptions.Encryption
+	}
+	if opts.Algorithm == 0 {
+		opts.Algorithm = DefaultRsaOptions.Algorithm
+	}
+	return opts
+}
+
+// SignRsa returns the signature of the message using the padding and hash of the options
+func SignRsa(msg []byte, key *rsa.PrivateKey, opts RsaOptions) ([]byte, error) {
+	opts = opts.withDefaults()
+	if !opts.Hash.Available() {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	hashed := digest(opts.Hash.New, msg)
+	switch opts.Padding {
+	case RsaPkcs1v15:
+		return rsa.SignPKCS1v15(rand.Reader, key, opts.Hash, hashed)
+	case RsaPss:
+		return rsa.SignPSS(rand.Reader, key, opts.Hash, hashed, &rsa.PSSOptions{SaltLength: opts.SaltLength})
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+}
+
+// VerifyRsa checks the signature of the message using the padding and hash of the options
+func VerifyRsa(msg, sig []byte, pk *rsa.PublicKey, opts RsaOptions) error {
+	opts = opts.withDefaults()
+	if !opts.Hash.Available() {
+		return ErrUnsupportedAlgorithm
+	}
+	hashed := digest(opts.Hash.New, msg)
+	switch opts.Padding {
+	case RsaPkcs1v15:
+		return rsa.VerifyPKCS1v15(pk, opts.Hash, hashed, sig)
+	case RsaPss:
+		return rsa.VerifyPSS(pk, opts.Hash, hashed, sig, &rsa.PSSOptions{SaltLength: opts.SaltLength})
+	default:
+		return ErrUnsupportedAlgorithm
+	}
+}
+
+// EncryptRsa encrypts the message with the public key using the encryption mode of the options
+func EncryptRsa(msg []byte, pub *rsa.PublicKey, opts RsaOptions) ([]byte, error) {
+	opts = opts.withDefaults()
+	if !opts.Hash.Available() {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	switch opts.Encryption {
+	case RsaOaep:
+		return rsa.EncryptOAEP(opts.Hash.New(), rand.Reader, pub, msg, opts.Label)
+	case RsaHybrid:
+		dataKey := make([]byte, symmKeySize)
+		if _, err := rand.Read(dataKey); err != nil {
+			return nil, err
+		}
+		wrappedKey, err := rsa.EncryptOAEP(opts.Hash.New(), rand.Reader, pub, dataKey, opts.Label)
+		if err != nil {
+			return nil, err
+		}
+		header := make([]byte, 3, 3+len(wrappedKey))
+		header[0] = rsaHybridVersion
+		binary.BigEndian.PutUint16(header[1:3], uint16(len(wrappedKey)))
+		header = append(header, wrappedKey...)
+
+		ciphertext, err := EncryptUsingSymmKeyWithConfig(msg, dataKey, SymmKeyConfig{
+			Algorithm:      opts.Algorithm,
+			AssociatedData: symmAssociatedData(header, opts.Label),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return append(header, ciphertext...), nil
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+}
+
+// DecryptRsa decrypts a message encrypted by EncryptRsa with the same options
+func DecryptRsa(ciphertext []byte, priv *rsa.PrivateKey, opts RsaOptions) ([]byte, error) {
+	opts = opts.withDefaults()
+	if !opts.Hash.Available() {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	switch opts.Encryption {
+	case RsaOaep:
+		return rsa.DecryptOAEP(opts.Hash.New(), rand.Reader, priv, ciphertext, opts.Label)
+	case RsaHybrid:
+		if len(ciphertext) < 3 {
+			return nil, ErrCiphertextTooShort
+		}
+		if ciphertext[0] != rsaHybridVersion {
+			return nil, ErrUnsupportedVersion
+		}
+		headerLen := 3 + int(binary.BigEndian.Uint16(ciphertext[1:3]))
+		if len(ciphertext) < headerLen {
+			return nil, ErrCiphertextTooShort
+		}
+		header := ciphertext[:headerLen]
+
+		dataKey, err := rsa.DecryptOAEP(opts.Hash.New(), rand.Reader, priv, header[3:], opts.Label)
+		if err != nil {
+			return nil, err
+		}
+		return DecryptUsingSymmKeyWithConfig(ciphertext[headerLen:], dataKey, SymmKeyConfig{
+			AssociatedData: symmAssociatedData(header, opts.Label),
+		})
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+}
@@ -0,0 +1,192 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+var (
+	// ErrInvalidThreshold is returned when the threshold or the number of shares is out of range
+	ErrInvalidThreshold = errors.New("threshold must be at least 2 and at most the number of shares, which is at most 255")
+	// ErrInvalidShare is returned when a share is malformed or corrupted
+	ErrInvalidShare = errors.New("invalid secret share")
+	// ErrInsufficientShares is returned when less shares than the threshold are combined
+	ErrInsufficientShares = errors.New("not enough secret shares")
+	// ErrShareMismatch is returned when the shares do not belong to the same secret
+	ErrShareMismatch = errors.New("secret shares do not belong together")
+)
+
+// Share layout: version | set id (4) | threshold | x | y (len(secret) + shareDigestSize) | crc32 (4)
+const (
+	shareVersion    = 1
+	shareHeaderSize = 7
+	shareDigestSize = 8
+	shareCRCSize    = 4
+)
+
+// SplitSecret splits the secret into n shares of which any threshold combine to the secret using
+// Shamir's Secret Sharing over GF(256). Fewer shares reveal nothing about the secret.
+// Every share carries a checksum, and a digest of the secret is shared along, so corrupted shares
+// and shares of different secrets are detected when combining
+func SplitSecret(secret []byte, n, threshold int) ([][]byte, error) {
+	if threshold < 2 || threshold > n || n > 255 {
+		return nil, ErrInvalidThreshold
+	}
+	if len(secret) == 0 {
+		return nil, ErrInvalidShare
+	}
+
+	setID := make([]byte, 4)
+	if _, err := rand.Read(setID); err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(secret)
+	payload := append(append([]byte{}, secret...), digest[:shareDigestSize]...)
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		share := make([]byte, shareHeaderSize, shareHeaderSize+len(payload)+shareCRCSize)
+		share[0] = shareVersion
+		copy(share[1:5], setID)
+		share[5] = byte(threshold)
+		share[6] = byte(i + 1)
+		shares[i] = share
+	}
+
+	// One random polynomial of degree threshold-1 per byte, with the byte as constant term
+	coefficients := make([]byte, threshold)
+	for _, b := range payload {
+		coefficients[0] = b
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, err
+		}
+		for i := range shares {
+			shares[i] = append(shares[i], evaluatePolynomial(coefficients, byte(i+1)))
+		}
+	}
+	for i, share := range shares {
+		shares[i] = appendCRC(share)
+	}
+	return shares, nil
+}
+
+// CombineShares recovers the secret from at least threshold shares created by SplitSecret
+func CombineShares(shares [][]byte) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, ErrInsufficientShares
+	}
+	for _, share := range shares {
+		if err := checkShare(share); err != nil {
+			return nil, err
+		}
+	}
+
+	first := shares[0]
+	threshold := int(first[5])
+	seen := make(map[byte]bool, len(shares))
+	unique := make([][]byte, 0, threshold)
+	for _, share := range shares {
+		if len(share) != len(first) || !bytes.Equal(share[:6], first[:6]) {
+			return nil, ErrShareMismatch
+		}
+		if !seen[share[6]] {
+			seen[share[6]] = true
+			unique = append(unique, share)
+		}
+	}
+	if len(unique) < threshold {
+		return nil, ErrInsufficientShares
+	}
+	unique = unique[:threshold]
+
+	size := len(first) - shareHeaderSize - shareCRCSize
+	payload := make([]byte, size)
+	xs := make([]byte, threshold)
+	ys := make([]byte, threshold)
+	for i, share := range unique {
+		xs[i] = share[6]
+	}
+	for j := 0; j < size; j++ {
+		for i, share := range unique {
+			ys[i] = share[shareHeaderSize+j]
+		}
+		payload[j] = interpolateAtZero(xs, ys)
+	}
+
+	secret := payload[:size-shareDigestSize]
+	digest := sha256.Sum256(secret)
+	if !bytes.Equal(digest[:shareDigestSize], payload[size-shareDigestSize:]) {
+		return nil, ErrShareMismatch
+	}
+	return secret, nil
+}
+
+// checkShare validates the layout and the checksum of a share
+func checkShare(share []byte) error {
+	if len(share) < shareHeaderSize+shareDigestSize+1+shareCRCSize || share[0] != shareVersion || share[5] < 2 || share[6] == 0 {
+		return ErrInvalidShare
+	}
+	body := share[:len(share)-shareCRCSize]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(share[len(body):]) {
+		return ErrInvalidShare
+	}
+	return nil
+}
+
+// appendCRC appends the crc32 checksum of the share
+func appendCRC(share []byte) []byte {
+	crc := make([]byte, shareCRCSize)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(share))
+	return append(share, crc...)
+}
+
+// evaluatePolynomial evaluates the polynomial at x using Horner's method
+func evaluatePolynomial(coefficients []byte, x byte) byte {
+	var result byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result = gfMul(result, x) ^ coefficients[i]
+	}
+	return result
+}
+
+// interpolateAtZero returns the value at zero of the polynomial through the points using Lagrange interpolation
+func interpolateAtZero(xs, ys []byte) byte {
+	var result byte
+	for i := range xs {
+		// basis = prod x_j / (x_j - x_i). Subtraction is xor in GF(256)
+		basis := byte(1)
+		for j := range xs {
+			if i != j {
+				basis = gfMul(basis, gfMul(xs[j], gfInverse(xs[j]^xs[i])))
+			}
+		}
+		result ^= gfMul(ys[i], basis)
+	}
+	return result
+}
+
+// gfMul multiplies in GF(256) with the AES polynomial without data dependent branches
+func gfMul(a, b byte) byte {
+	var result byte
+	for i := 0; i < 8; i++ {
+		result ^= a & -(b & 1)
+		carry := -(a >> 7)
+		a = a<<1 ^ 0x1b&carry
+		b >>= 1
+	}
+	return result
+}
+
+// gfInverse returns the multiplicative inverse in GF(256) as a^254
+func gfInverse(a byte) byte {
+	result := byte(1)
+	for i := 0; i < 7; i++ {
+		a = gfMul(a, a)
+		result = gfMul(result, a)
+	}
+	return result
+}
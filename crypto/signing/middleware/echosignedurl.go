@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/adityak368/swissknife/crypto/internal/echoerror"
+	"github.com/adityak368/swissknife/crypto/signing"
+	"github.com/adityak368/swissknife/response"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// EchoSignedURLConfig defines the config for the signed url middleware
+type EchoSignedURLConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+	// ErrorHandler writes the response for a rejected request. Defaults to an untranslated response.Message.
+	// Ex: jwtauth.LocalizedErrorHandler
+	ErrorHandler func(c echo.Context, err *response.Error) error
+}
+
+// DefaultEchoSignedURLConfig defines the default signed url middleware config
+var DefaultEchoSignedURLConfig = EchoSignedURLConfig{
+	Skipper:      middleware.DefaultSkipper,
+	ErrorHandler: echoerror.Message,
+}
+
+// EchoSignedURL returns a middleware for echo that rejects requests without a valid, unexpired url signature
+func EchoSignedURL(signer *signing.Signer) echo.MiddlewareFunc {
+	return EchoSignedURLWithConfig(signer, DefaultEchoSignedURLConfig)
+}
+
+// EchoSignedURLWithConfig returns a middleware for echo with config
+func EchoSignedURLWithConfig(signer *signing.Signer, config EchoSignedURLConfig) echo.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultEchoSignedURLConfig.Skipper
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = DefaultEchoSignedURLConfig.ErrorHandler
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			if err := signer.VerifyRequest(c.Request()); err != nil {
+				var responseError *response.Error
+				if errors.As(err, &responseError) {
+					return config.ErrorHandler(c, responseError)
+				}
+				return err
+			}
+			return next(c)
+		}
+	}
+}
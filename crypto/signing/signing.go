@@ -0,0 +1,255 @@
+package signing
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adityak368/swissknife/crypto"
+	"github.com/adityak368/swissknife/response"
+)
+
+// Errors returned when a signed url or payload is rejected. They are response errors, so the message id can be translated
+var (
+	ErrSignatureMissing = response.NewError(http.StatusForbidden, "SignatureMissing")
+	ErrSignatureInvalid = response.NewError(http.StatusForbidden, "SignatureInvalid")
+	ErrSignatureExpired = response.NewError(http.StatusForbidden, "SignatureExpired")
+)
+
+// Domain separation prefixes of the signed messages, so a signed url can never be used as a signed payload
+const (
+	urlContext     = "swissknife signed url v1"
+	payloadContext = "swissknife signed payload v1"
+)
+
+// Config defines the signer config
+type Config struct {
+	// TTL is the lifetime of signatures created without an explicit expiry
+	TTL time.Duration
+	// SignatureParam, ExpiresParam and KeyIDParam are the query parameters added to signed urls
+	SignatureParam string
+	ExpiresParam   string
+	KeyIDParam     string
+	// IncludeHost binds signed urls to their host. Leave it disabled if a proxy rewrites the host
+	IncludeHost bool
+	// Now returns the current time. Defaults to time.Now
+	Now func() time.Time
+}
+
+// DefaultConfig defines the default signer config
+var DefaultConfig = Config{
+	TTL:            time.Hour,
+	SignatureParam: "signature",
+	ExpiresParam:   "expires",
+	KeyIDParam:     "kid",
+	Now:            time.Now,
+}
+
+// Signer creates and verifies expiring HMAC-SHA256 signatures of urls and payloads with a secret key of the keystore.
+// The id of the key version is part of every signature, so signatures stay valid after the key is rotated until the old
+// version is retired
+type Signer struct {
+	store   *crypto.KeyStore
+	keyName string
+	config  Config
+}
+
+// NewSigner Creates a new signer using the named secret key with the default config
+func NewSigner(store *crypto.KeyStore, keyName string) *Signer {
+	return NewSignerWithConfig(store, keyName, DefaultConfig)
+}
+
+// NewSignerWithConfig Creates a new signer using the named secret key with the config
+func NewSignerWithConfig(store *crypto.KeyStore, keyName string, config Config) *Signer {
+	if config.TTL <= 0 {
+		config.TTL = DefaultConfig.TTL
+	}
+	if config.SignatureParam == "" {
+		config.SignatureParam = DefaultConfig.SignatureParam
+	}
+	if config.ExpiresParam == "" {
+		config.ExpiresParam = DefaultConfig.ExpiresParam
+	}
+	if config.KeyIDParam == "" {
+		config.KeyIDParam = DefaultConfig.KeyIDParam
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &Signer{
+		store:   store,
+		keyName: keyName,
+		config:  config,
+	}
+}
+
+// SignURL adds the expiry, key id and signature query parameters to the url. It expires after the TTL
+func (s *Signer) SignURL(rawURL string) (string, error) {
+	return s.SignURLWithExpiry(rawURL, s.config.Now().Add(s.config.TTL))
+}
+
+// SignURLWithExpiry adds the expiry, key id and signature query parameters to the url
+func (s *Signer) SignURLWithExpiry(rawURL string, expiresAt time.Time) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	keyID, secret, err := s.activeSecret()
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	query.Del(s.config.SignatureParam)
+	query.Set(s.config.ExpiresParam, strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set(s.config.KeyIDParam, keyID)
+	u.RawQuery = query.Encode()
+
+	signature := crypto.HMAC(s.canonicalURL(u), secret, sha256.New)
+	query.Set(s.config.SignatureParam, base64.RawURLEncoding.EncodeToString(signature))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// VerifyURL checks the signature and the expiry of a signed url
+func (s *Signer) VerifyURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	return s.verifyURL(u)
+}
+
+// VerifyRequest checks the signature and the expiry of the url of an incoming request
+func (s *Signer) VerifyRequest(r *http.Request) error {
+	u := *r.URL
+	u.Host = r.Host
+	return s.verifyURL(&u)
+}
+
+// verifyURL checks the signature and the expiry of the url
+func (s *Signer) verifyURL(u *url.URL) error {
+	query := u.Query()
+	encodedSignature := query.Get(s.config.SignatureParam)
+	if encodedSignature == "" {
+		return ErrSignatureMissing
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	expires, err := strconv.ParseInt(query.Get(s.config.ExpiresParam), 10, 64)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	if err := s.verifyMAC(query.Get(s.config.KeyIDParam), s.canonicalURL(u), signature); err != nil {
+		return err
+	}
+	return s.checkExpiry(expires)
+}
+
+// Sign signs the payload into a url safe token that expires after the TTL.
+// The purpose, Ex: "password-reset", must match on verification, so a token can not be used for something else
+func (s *Signer) Sign(purpose string, payload []byte) (string, error) {
+	return s.SignWithExpiry(purpose, payload, s.config.Now().Add(s.config.TTL))
+}
+
+// SignWithExpiry signs the payload into a url safe token of the form kid.expires.payload.signature
+func (s *Signer) SignWithExpiry(purpose string, payload []byte, expiresAt time.Time) (string, error) {
+	keyID, secret, err := s.activeSecret()
+	if err != nil {
+		return "", err
+	}
+	signed := keyID + "." + strconv.FormatInt(expiresAt.Unix(), 10) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature := crypto.HMAC(canonicalPayload(purpose, signed), secret, sha256.New)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Verify checks the signature, the purpose and the expiry of a token and returns its payload
+func (s *Signer) Verify(purpose, token string) ([]byte, error) {
+	if token == "" {
+		return nil, ErrSignatureMissing
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 4 {
+		return nil, ErrSignatureInvalid
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, ErrSignatureInvalid
+	}
+	signed := token[:len(token)-len(parts[3])-1]
+	if err := s.verifyMAC(parts[0], canonicalPayload(purpose, signed), signature); err != nil {
+		return nil, err
+	}
+
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, ErrSignatureInvalid
+	}
+	if err := s.checkExpiry(expires); err != nil {
+		return nil, err
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrSignatureInvalid
+	}
+	return payload, nil
+}
+
+// canonicalURL returns the signed representation of the url: the host if enabled, the escaped path and
+// the query without the signature, sorted by key. Scheme, user info and fragment are not signed
+func (s *Signer) canonicalURL(u *url.URL) []byte {
+	host := ""
+	if s.config.IncludeHost {
+		host = strings.ToLower(u.Host)
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	query := u.Query()
+	query.Del(s.config.SignatureParam)
+	return []byte(urlContext + "\n" + host + "\n" + path + "\n" + query.Encode())
+}
+
+// canonicalPayload returns the signed representation of a token
+func canonicalPayload(purpose, signed string) []byte {
+	return []byte(payloadContext + "\n" + purpose + "\n" + signed)
+}
+
+// activeSecret returns the id and the secret of the active version of the key
+func (s *Signer) activeSecret() (string, []byte, error) {
+	version, ok := s.store.ActiveKeyVersion(s.keyName)
+	if !ok {
+		return "", nil, crypto.ErrNoActiveKey
+	}
+	if version.Key.Type() != crypto.KeyTypeSecret {
+		return "", nil, crypto.ErrUnsupportedKeyType
+	}
+	return version.ID, version.Key.Private().([]byte), nil
+}
+
+// verifyMAC checks the signature with the usable key version with the given id
+func (s *Signer) verifyMAC(keyID string, msg, signature []byte) error {
+	version, ok := s.store.KeyVersion(s.keyName, keyID)
+	if !ok || !version.Usable() || version.Key.Type() != crypto.KeyTypeSecret {
+		return ErrSignatureInvalid
+	}
+	if !crypto.ValidMAC(msg, version.Key.Private().([]byte), signature, sha256.New) {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+// checkExpiry rejects expiry times that are not in the future
+func (s *Signer) checkExpiry(expires int64) error {
+	if !s.config.Now().Before(time.Unix(expires, 0)) {
+		return ErrSignatureExpired
+	}
+	return nil
+}
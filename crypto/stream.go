@@ -0,0 +1,352 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Streaming encryption splits the plaintext into fixed size chunks that are sealed separately
+// (the STREAM construction). Each chunk nonce is noncePrefix | counter | lastChunkFlag, so chunks
+// can not be reordered, dropped or truncated without failing authentication.
+// The stream starts with the header: version | algorithm | chunkSize | salt | noncePrefix
+
+// streamVersion is the current version of the stream ciphertext format.
+// The high bit distinguishes stream ciphertexts from the single shot ones
+const streamVersion byte = 0x81
+
+// maxStreamChunkSize limits the memory used while decrypting untrusted streams
+const maxStreamChunkSize = 16 * 1024 * 1024
+
+var (
+	// ErrInvalidChunkSize is returned when the configured or decoded chunk size is out of range
+	ErrInvalidChunkSize = errors.New("invalid stream chunk size")
+	// ErrStreamTooLong is returned when the stream exceeds the maximum number of chunks
+	ErrStreamTooLong = errors.New("stream has too many chunks")
+	// ErrStreamClosed is returned when writing to a closed encryption stream
+	ErrStreamClosed = errors.New("write to closed encryption stream")
+)
+
+// StreamConfig defines the config for streaming encryption
+type StreamConfig struct {
+	// Algorithm is the aead used for encryption. Decryption reads it from the stream header
+	Algorithm AEADAlgorithm
+	// ChunkSize is the size of each plaintext chunk. Decryption reads it from the stream header
+	ChunkSize int
+	// AssociatedData is authenticated with every chunk. The same data must be given for decryption
+	AssociatedData []byte
+}
+
+// DefaultStreamConfig defines the default streaming encryption config
+var DefaultStreamConfig = StreamConfig{
+	Algorithm: XChaCha20Poly1305,
+	ChunkSize: 64 * 1024,
+}
+
+// EncryptStream Encrypts everything read from src into dst using the default stream config
+func EncryptStream(dst io.Writer, src io.Reader, privKey []byte) error {
+	return EncryptStreamWithConfig(dst, src, privKey, DefaultStreamConfig)
+}
+
+// EncryptStreamWithConfig Encrypts everything read from src into dst using the config
+func EncryptStreamWithConfig(dst io.Writer, src io.Reader, privKey []byte, config StreamConfig) error {
+	w, err := NewEncryptWriter(dst, privKey, config)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// DecryptStream Decrypts a stream produced by EncryptStream from src into dst
+func DecryptStream(dst io.Writer, src io.Reader, privKey []byte) error {
+	return DecryptStreamWithConfig(dst, src, privKey, DefaultStreamConfig)
+}
+
+// DecryptStreamWithConfig Decrypts a stream produced by EncryptStreamWithConfig from src into dst
+func DecryptStreamWithConfig(dst io.Writer, src io.Reader, privKey []byte, config StreamConfig) error {
+	r, err := NewDecryptReader(src, privKey, config)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, r)
+	return err
+}
+
+// encryptWriter encrypts everything written to it in chunks
+type encryptWriter struct {
+	w         io.Writer
+	aead      cipher.AEAD
+	nonce     []byte
+	ad        []byte
+	chunkSize int
+	counter   uint32
+	buf       []byte
+	out       []byte
+	closed    bool
+	err       error
+}
+
+// NewEncryptWriter returns a writer that encrypts everything written to it into w.
+// The stream header is written to w immediately. Close must be called to write the final chunk,
+// it does not close w
+func NewEncryptWriter(w io.Writer, privKey []byte, config StreamConfig) (io.WriteCloser, error) {
+	if len(privKey) == 0 {
+		return nil, ErrEmptyKey
+	}
+	if config.Algorithm == 0 {
+		config.Algorithm = DefaultStreamConfig.Algorithm
+	}
+	if config.ChunkSize == 0 {
+		config.ChunkSize = DefaultStreamConfig.ChunkSize
+	}
+	if config.ChunkSize < 0 || config.ChunkSize > maxStreamChunkSize {
+		return nil, ErrInvalidChunkSize
+	}
+
+	nonceSize, err := aeadNonceSize(config.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	// The last 5 bytes of the nonce hold the chunk counter and the last chunk flag
+	header := make([]byte, 6+symmSaltSize+nonceSize-5)
+	header[0] = streamVersion
+	header[1] = byte(config.Algorithm)
+	binary.BigEndian.PutUint32(header[2:6], uint32(config.ChunkSize))
+	if _, err := rand.Read(header[6:]); err != nil {
+		return nil, err
+	}
+	salt := header[6 : 6+symmSaltSize]
+	noncePrefix := header[6+symmSaltSize:]
+
+	aead, err := newDerivedAEAD(config.Algorithm, privKey, salt, streamVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, nonceSize)
+	copy(nonce, noncePrefix)
+
+	return &encryptWriter{
+		w:         w,
+		aead:      aead,
+		nonce:     nonce,
+		ad:        symmAssociatedData(header, config.AssociatedData),
+		chunkSize: config.ChunkSize,
+		buf:       make([]byte, 0, config.ChunkSize),
+		out:       make([]byte, 0, config.ChunkSize+aead.Overhead()),
+	}, nil
+}
+
+// Write implements io.Writer
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, ErrStreamClosed
+	}
+	if e.err != nil {
+		return 0, e.err
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// A full chunk is only flushed once more data arrives, so the final chunk is never empty
+		// unless the whole stream is
+		if len(e.buf) == e.chunkSize {
+			if e.err = e.flush(false); e.err != nil {
+				return written, e.err
+			}
+		}
+		n := e.chunkSize - len(e.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+		e.buf = append(e.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close writes the final chunk. It does not close the underlying writer
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	if e.err != nil {
+		return e.err
+	}
+	e.err = e.flush(true)
+	return e.err
+}
+
+// flush seals the buffered chunk and writes it
+func (e *encryptWriter) flush(last bool) error {
+	if err := setStreamNonce(e.nonce, e.counter, last); err != nil {
+		return err
+	}
+	e.out = e.aead.Seal(e.out[:0], e.nonce, e.buf, e.ad)
+	e.buf = e.buf[:0]
+	e.counter++
+	_, err := e.w.Write(e.out)
+	return err
+}
+
+// decryptReader decrypts a chunked stream
+type decryptReader struct {
+	r         io.Reader
+	aead      cipher.AEAD
+	nonce     []byte
+	ad        []byte
+	counter   uint32
+	buf       []byte
+	hasCarry  bool
+	out       []byte
+	plaintext []byte
+	done      bool
+	err       error
+}
+
+// NewDecryptReader returns a reader that decrypts the stream read from r.
+// The stream header is read immediately. Plaintext is only returned after its chunk has been authenticated
+func NewDecryptReader(r io.Reader, privKey []byte, config StreamConfig) (io.Reader, error) {
+	if len(privKey) == 0 {
+		return nil, ErrEmptyKey
+	}
+
+	prefix := make([]byte, 6)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrCiphertextTooShort
+		}
+		return nil, err
+	}
+	if prefix[0] != streamVersion {
+		return nil, ErrUnsupportedVersion
+	}
+
+	alg := AEADAlgorithm(prefix[1])
+	nonceSize, err := aeadNonceSize(alg)
+	if err != nil {
+		return nil, err
+	}
+	chunkSize := binary.BigEndian.Uint32(prefix[2:6])
+	if chunkSize == 0 || chunkSize > maxStreamChunkSize {
+		return nil, ErrInvalidChunkSize
+	}
+
+	header := make([]byte, 6+symmSaltSize+nonceSize-5)
+	copy(header, prefix)
+	if _, err := io.ReadFull(r, header[6:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrCiphertextTooShort
+		}
+		return nil, err
+	}
+	salt := header[6 : 6+symmSaltSize]
+	noncePrefix := header[6+symmSaltSize:]
+
+	aead, err := newDerivedAEAD(alg, privKey, salt, streamVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, nonceSize)
+	copy(nonce, noncePrefix)
+
+	return &decryptReader{
+		r:     r,
+		aead:  aead,
+		nonce: nonce,
+		ad:    symmAssociatedData(header, config.AssociatedData),
+		// One extra byte is read ahead to detect whether a chunk is the last one
+		buf: make([]byte, int(chunkSize)+aead.Overhead()+1),
+		out: make([]byte, 0, chunkSize),
+	}, nil
+}
+
+// Read implements io.Reader
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plaintext) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		if d.done {
+			return 0, io.EOF
+		}
+		if d.err = d.readChunk(); d.err != nil {
+			return 0, d.err
+		}
+	}
+	n := copy(p, d.plaintext)
+	d.plaintext = d.plaintext[n:]
+	return n, nil
+}
+
+// readChunk reads, authenticates and decrypts the next chunk
+func (d *decryptReader) readChunk() error {
+	start := 0
+	if d.hasCarry {
+		start = 1
+	}
+	n, err := io.ReadFull(d.r, d.buf[start:])
+	n += start
+
+	var last bool
+	switch err {
+	case nil:
+		last = false
+	case io.EOF, io.ErrUnexpectedEOF:
+		last = true
+	default:
+		return err
+	}
+
+	chunk := d.buf[:n]
+	if !last {
+		chunk = d.buf[:n-1]
+	}
+	if len(chunk) < d.aead.Overhead() {
+		return io.ErrUnexpectedEOF
+	}
+
+	if err := setStreamNonce(d.nonce, d.counter, last); err != nil {
+		return err
+	}
+	plaintext, err := d.aead.Open(d.out[:0], d.nonce, chunk, d.ad)
+	if err != nil {
+		return err
+	}
+	d.counter++
+	d.plaintext = plaintext
+	d.done = last
+
+	if !last {
+		d.buf[0] = d.buf[n-1]
+		d.hasCarry = true
+	}
+	return nil
+}
+
+// setStreamNonce writes the chunk counter and the last chunk flag into the nonce
+func setStreamNonce(nonce []byte, counter uint32, last bool) error {
+	if counter == ^uint32(0) {
+		return ErrStreamTooLong
+	}
+	binary.BigEndian.PutUint32(nonce[len(nonce)-5:], counter)
+	if last {
+		nonce[len(nonce)-1] = 1
+	} else {
+		nonce[len(nonce)-1] = 0
+	}
+	return nil
+}
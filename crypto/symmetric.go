@@ -0,0 +1,219 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// AEADAlgorithm defines the authenticated encryption algorithm used for symmetric encryption
+type AEADAlgorithm byte
+
+const (
+	// XChaCha20Poly1305 encrypts using XChaCha20-Poly1305 with a random 24 byte nonce
+	XChaCha20Poly1305 AEADAlgorithm = iota + 1
+	// AES256GCM encrypts using AES-256 in GCM mode with a random 12 byte nonce
+	AES256GCM
+	// AES256GCMSIV encrypts using the nonce misuse resistant AES-256-GCM-SIV (RFC 8452)
+	AES256GCMSIV
+)
+
+// String returns the name of the algorithm
+func (alg AEADAlgorithm) String() string {
+	switch alg {
+	case XChaCha20Poly1305:
+		return "XChaCha20-Poly1305"
+	case AES256GCM:
+		return "AES-256-GCM"
+	case AES256GCMSIV:
+		return "AES-256-GCM-SIV"
+	default:
+		return fmt.Sprintf("AEADAlgorithm(%d)", byte(alg))
+	}
+}
+
+// symmVersion is the current version of the symmetric ciphertext format
+const symmVersion byte = 1
+
+// symmSaltSize is the size of the random salt used to derive the encryption key
+const symmSaltSize = 32
+
+// symmKeySize is the size of the derived encryption key. All supported algorithms use 256 bit keys
+const symmKeySize = 32
+
+var (
+	// ErrCiphertextTooShort is returned when the ciphertext is shorter than its header
+	ErrCiphertextTooShort = errors.New("ciphertext too short")
+	// ErrUnsupportedVersion is returned when the ciphertext was produced by an unknown format version
+	ErrUnsupportedVersion = errors.New("unsupported ciphertext version")
+	// ErrUnsupportedAlgorithm is returned when the requested algorithm is not supported
+	ErrUnsupportedAlgorithm = errors.New("unsupported encryption algorithm")
+	// ErrEmptyKey is returned when an empty key is used for encryption
+	ErrEmptyKey = errors.New("empty encryption key")
+)
+
+// SymmKeyConfig defines the config for symmetric encryption
+type SymmKeyConfig struct {
+	// Algorithm is the aead used for encryption. Decryption reads it from the ciphertext header
+	Algorithm AEADAlgorithm
+	// AssociatedData is authenticated but not encrypted. The same data must be given for decryption
+	AssociatedData []byte
+}
+
+// DefaultSymmKeyConfig defines the default symmetric encryption config
+var DefaultSymmKeyConfig = SymmKeyConfig{
+	Algorithm: XChaCha20Poly1305,
+}
+
+// EncryptUsingSymmKey Encrypts data using the default symmetric encryption config
+func EncryptUsingSymmKey(msg, privKey []byte) ([]byte, error) {
+	return EncryptUsingSymmKeyWithConfig(msg, privKey, DefaultSymmKeyConfig)
+}
+
+// DecryptUsingSymmKey Decrypts data encrypted by EncryptUsingSymmKey
+func DecryptUsingSymmKey(encryptedMsg, privKey []byte) ([]byte, error) {
+	return DecryptUsingSymmKeyWithConfig(encryptedMsg, privKey, DefaultSymmKeyConfig)
+}
+
+// EncryptUsingSymmKeyWithConfig Encrypts data using the algorithm in the config.
+// The encryption key is derived from privKey using HKDF-SHA512 with a random salt.
+// The output is self describing: version | algorithm | salt | nonce | ciphertext
+func EncryptUsingSymmKeyWithConfig(msg, privKey []byte, config SymmKeyConfig) ([]byte, error) {
+	if len(privKey) == 0 {
+		return nil, ErrEmptyKey
+	}
+	if config.Algorithm == 0 {
+		config.Algorithm = DefaultSymmKeyConfig.Algorithm
+	}
+
+	nonceSize, err := aeadNonceSize(config.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	headerSize := 2 + symmSaltSize + nonceSize
+	header := make([]byte, headerSize, headerSize+len(msg)+aeadTagSize)
+	header[0] = symmVersion
+	header[1] = byte(config.Algorithm)
+	if _, err := rand.Read(header[2:]); err != nil {
+		return nil, err
+	}
+	salt := header[2 : 2+symmSaltSize]
+	nonce := header[2+symmSaltSize:]
+
+	aead, err := newDerivedAEAD(config.Algorithm, privKey, salt, symmVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	// Encrypt the message and append the ciphertext to the header.
+	return aead.Seal(header, nonce, msg, symmAssociatedData(header, config.AssociatedData)), nil
+}
+
+// DecryptUsingSymmKeyWithConfig Decrypts data encrypted by EncryptUsingSymmKeyWithConfig.
+// The algorithm is read from the ciphertext header, so only the associated data of the config is used
+func DecryptUsingSymmKeyWithConfig(encryptedMsg, privKey []byte, config SymmKeyConfig) ([]byte, error) {
+	if len(privKey) == 0 {
+		return nil, ErrEmptyKey
+	}
+	if len(encryptedMsg) < 2 {
+		return nil, ErrCiphertextTooShort
+	}
+	if encryptedMsg[0] != symmVersion {
+		return nil, ErrUnsupportedVersion
+	}
+
+	alg := AEADAlgorithm(encryptedMsg[1])
+	nonceSize, err := aeadNonceSize(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	headerSize := 2 + symmSaltSize + nonceSize
+	if len(encryptedMsg) < headerSize+aeadTagSize {
+		return nil, ErrCiphertextTooShort
+	}
+	header, ciphertext := encryptedMsg[:headerSize], encryptedMsg[headerSize:]
+	salt := header[2 : 2+symmSaltSize]
+	nonce := header[2+symmSaltSize:]
+
+	aead, err := newDerivedAEAD(alg, privKey, salt, symmVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decrypt the message and check it wasn't tampered with.
+	return aead.Open(nil, nonce, ciphertext, symmAssociatedData(header, config.AssociatedData))
+}
+
+// aeadTagSize is the size of the authentication tag of all supported algorithms
+const aeadTagSize = 16
+
+// aeadNonceSize returns the nonce size of the algorithm
+func aeadNonceSize(alg AEADAlgorithm) (int, error) {
+	switch alg {
+	case XChaCha20Poly1305:
+		return chacha20poly1305.NonceSizeX, nil
+	case AES256GCM:
+		return 12, nil
+	case AES256GCMSIV:
+		return gcmSivNonceSize, nil
+	default:
+		return 0, ErrUnsupportedAlgorithm
+	}
+}
+
+// newAEAD creates the aead for the algorithm using the given 32 byte key
+func newAEAD(alg AEADAlgorithm, key []byte) (cipher.AEAD, error) {
+	switch alg {
+	case XChaCha20Poly1305:
+		return chacha20poly1305.NewX(key)
+	case AES256GCM:
+		if len(key) != symmKeySize {
+			return nil, errors.New("invalid AES-256 key size")
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case AES256GCMSIV:
+		return newGCMSIV(key)
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+}
+
+// newDerivedAEAD derives the encryption key for the algorithm from the secret and salt and creates the aead.
+// The format version is part of the derivation so that keys are never shared between formats
+func newDerivedAEAD(alg AEADAlgorithm, secret, salt []byte, version byte) (cipher.AEAD, error) {
+	info := []byte{'s', 'k', 's', 'y', 'm', 'm', version, byte(alg)}
+	key, err := deriveKey(secret, salt, info, symmKeySize)
+	if err != nil {
+		return nil, err
+	}
+	return newAEAD(alg, key)
+}
+
+// deriveKey derives a key of the given size using HKDF-SHA512
+func deriveKey(secret, salt, info []byte, size int) ([]byte, error) {
+	key := make([]byte, size)
+	if _, err := io.ReadFull(hkdf.New(sha512.New, secret, salt, info), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// symmAssociatedData binds the header to the caller supplied associated data
+func symmAssociatedData(header, associatedData []byte) []byte {
+	ad := make([]byte, 0, len(header)+len(associatedData))
+	ad = append(ad, header...)
+	return append(ad, associatedData...)
+}
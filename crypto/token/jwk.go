@@ -0,0 +1,178 @@
+package token
+
+import (
+	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+
+	"github.com/adityak368/swissknife/crypto"
+)
+
+// ErrInvalidJWK is returned when a json web key can not be decoded
+var ErrInvalidJWK = errors.New("invalid json web key")
+
+// JWK is a public json web key (RFC 7517) of type RSA, EC or OKP
+type JWK struct {
+	KeyType   string    `json:"kty"`
+	KeyID     string    `json:"kid,omitempty"`
+	Use       string    `json:"use,omitempty"`
+	Algorithm Algorithm `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// JWKS is a json web key set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Key returns the key with the given id
+func (s *JWKS) Key(keyID string) (*JWK, bool) {
+	for i := range s.Keys {
+		if s.Keys[i].KeyID == keyID {
+			return &s.Keys[i], true
+		}
+	}
+	return nil, false
+}
+
+// NewJWK encodes a rsa, ecdsa or ed25519 public key as a json web key
+func NewJWK(pub gocrypto.PublicKey, keyID string) (*JWK, error) {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return &JWK{
+			KeyType: "RSA",
+			KeyID:   keyID,
+			Use:     "sig",
+			N:       base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		var alg Algorithm
+		switch pub.Curve {
+		case elliptic.P256():
+			alg = ES256
+		case elliptic.P384():
+			alg = ES384
+		case elliptic.P521():
+			alg = ES512
+		default:
+			return nil, crypto.ErrUnsupportedKeyType
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		x := make([]byte, size)
+		y := make([]byte, size)
+		pub.X.FillBytes(x)
+		pub.Y.FillBytes(y)
+		return &JWK{
+			KeyType:   "EC",
+			KeyID:     keyID,
+			Use:       "sig",
+			Algorithm: alg,
+			Curve:     pub.Curve.Params().Name,
+			X:         base64.RawURLEncoding.EncodeToString(x),
+			Y:         base64.RawURLEncoding.EncodeToString(y),
+		}, nil
+	case ed25519.PublicKey:
+		return &JWK{
+			KeyType:   "OKP",
+			KeyID:     keyID,
+			Use:       "sig",
+			Algorithm: EdDSA,
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(pub),
+		}, nil
+	default:
+		return nil, crypto.ErrUnsupportedKeyType
+	}
+}
+
+// PublicKey decodes the public key of the json web key
+func (k *JWK) PublicKey() (gocrypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, ErrInvalidJWK
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrInvalidJWK
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, ErrInvalidJWK
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, ErrInvalidJWK
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, ErrInvalidJWK
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, ErrInvalidJWK
+	}
+}
+
+// NewJWKS builds the json web key set of the public halves of the named asymmetric keys in the keystore.
+// Every key is published for signing, so only name the keys tokens are signed with, never keys used for
+// encryption. Every version that is not retired is included, so tokens signed before a rotation stay verifiable
+func NewJWKS(store *crypto.KeyStore, keyNames ...string) (*JWKS, error) {
+	jwks := &JWKS{Keys: []JWK{}}
+	for _, keyName := range keyNames {
+		for _, version := range store.KeyVersions(keyName) {
+			if !version.Usable() || version.Key.Type() == crypto.KeyTypeSecret {
+				continue
+			}
+			jwk, err := NewJWK(version.Key.Public(), version.ID)
+			if err != nil {
+				return nil, err
+			}
+			jwks.Keys = append(jwks.Keys, *jwk)
+		}
+	}
+	return jwks, nil
+}
+
+// decodeBigInt decodes a base64url encoded unsigned big endian integer
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, ErrInvalidJWK
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,192 @@
+package token
+
+import (
+	gocrypto "crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Verifier defines the interface for token verifiers
+type Verifier interface {
+	Verify(token string, claims RegisteredClaims) error
+}
+
+// JWKSClientConfig defines the config for the remote json web key set client
+type JWKSClientConfig struct {
+	// HTTPClient is used to fetch the key set
+	HTTPClient *http.Client
+	// CacheTTL is how long a fetched key set is used before it is fetched again
+	CacheTTL time.Duration
+	// MinRefreshInterval limits how often an unknown kid or a stale key set triggers a fetch
+	MinRefreshInterval time.Duration
+}
+
+// DefaultJWKSClientConfig defines the default remote json web key set client config
+var DefaultJWKSClientConfig = JWKSClientConfig{
+	HTTPClient:         &http.Client{Timeout: 10 * time.Second},
+	CacheTTL:           15 * time.Minute,
+	MinRefreshInterval: 30 * time.Second,
+}
+
+// maxJWKSSize limits the size of a fetched key set
+const maxJWKSSize = 1 << 20
+
+// JWKSClient fetches and caches the json web key set of another service.
+// An unknown kid refreshes the cached key set, so keys rotated by the other service are picked up.
+// A stale key set keeps being served while it is refreshed in the background
+type JWKSClient struct {
+	url         string
+	config      JWKSClientConfig
+	mu          sync.RWMutex
+	keys        map[string]gocrypto.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	inflight    *jwksFetch
+}
+
+// jwksFetch is a fetch of the key set shared by all callers waiting for it
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// NewJWKSClient Creates a new json web key set client for the url with the default config
+func NewJWKSClient(url string) *JWKSClient {
+	return NewJWKSClientWithConfig(url, DefaultJWKSClientConfig)
+}
+
+// NewJWKSClientWithConfig Creates a new json web key set client for the url with the config
+func NewJWKSClientWithConfig(url string, config JWKSClientConfig) *JWKSClient {
+	if config.HTTPClient == nil {
+		config.HTTPClient = DefaultJWKSClientConfig.HTTPClient
+	}
+	if config.CacheTTL <= 0 {
+		config.CacheTTL = DefaultJWKSClientConfig.CacheTTL
+	}
+	if config.MinRefreshInterval <= 0 {
+		config.MinRefreshInterval = DefaultJWKSClientConfig.MinRefreshInterval
+	}
+	return &JWKSClient{
+		url:    url,
+		config: config,
+	}
+}
+
+// Resolve returns the public key with the given id. It implements KeyResolver
+func (c *JWKSClient) Resolve(keyID string) (gocrypto.PublicKey, error) {
+	c.mu.RLock()
+	key, ok := c.keys[keyID]
+	fresh := c.keys != nil && time.Since(c.fetchedAt) < c.config.CacheTTL
+	due := c.inflight == nil && time.Since(c.lastAttempt) >= c.config.MinRefreshInterval
+	c.mu.RUnlock()
+	if ok {
+		if !fresh && due {
+			// The stale key set is served while it is refreshed in the background
+			go c.refresh(c.config.MinRefreshInterval)
+		}
+		return key, nil
+	}
+
+	// An unknown kid or a missing key set waits for a fetch, at most one per MinRefreshInterval
+	if err := c.refresh(c.config.MinRefreshInterval); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if key, ok := c.keys[keyID]; ok {
+		return key, nil
+	}
+	return nil, ErrTokenUnknownKey
+}
+
+// Refresh fetches the key set now
+func (c *JWKSClient) Refresh() error {
+	return c.refresh(0)
+}
+
+// refresh fetches the key set unless the last fetch started less than minInterval ago.
+// Concurrent callers share a single fetch, which runs without holding the lock
+func (c *JWKSClient) refresh(minInterval time.Duration) error {
+	c.mu.Lock()
+	if fetch := c.inflight; fetch != nil {
+		c.mu.Unlock()
+		<-fetch.done
+		return fetch.err
+	}
+	now := time.Now()
+	if now.Sub(c.lastAttempt) < minInterval {
+		c.mu.Unlock()
+		return nil
+	}
+	fetch := &jwksFetch{done: make(chan struct{})}
+	c.inflight = fetch
+	c.lastAttempt = now
+	c.mu.Unlock()
+
+	keys, err := c.fetch()
+
+	c.mu.Lock()
+	if err == nil {
+		c.keys = keys
+		c.fetchedAt = now
+	}
+	c.inflight = nil
+	c.mu.Unlock()
+
+	fetch.err = err
+	close(fetch.done)
+	return err
+}
+
+// fetch fetches and decodes the key set. Keys that can not be decoded are skipped
+func (c *JWKSClient) fetch() (map[string]gocrypto.PublicKey, error) {
+	resp, err := c.config.HTTPClient.Get(c.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching jwks failed with status %d", resp.StatusCode)
+	}
+
+	var jwks JWKS
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJWKSSize)).Decode(&jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]gocrypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.KeyID == "" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		pub, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.KeyID] = pub
+	}
+	return keys, nil
+}
+
+// jwksVerifier verifies tokens with the keys of a remote key set
+type jwksVerifier struct {
+	client *JWKSClient
+	config Config
+}
+
+// NewJWKSVerifier Creates a verifier for tokens signed by the keys of a remote key set
+func NewJWKSVerifier(client *JWKSClient, config Config) Verifier {
+	return &jwksVerifier{
+		client: client,
+		config: config,
+	}
+}
+
+// Verify implements the Verifier interface
+func (v *jwksVerifier) Verify(token string, claims RegisteredClaims) error {
+	return VerifyJWT(token, v.client.Resolve, claims, v.config)
+}
@@ -0,0 +1,317 @@
+package token
+
+import (
+	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"hash"
+	"math/big"
+	"strings"
+
+	"github.com/adityak368/swissknife/crypto"
+)
+
+// Algorithm defines a JWS signing algorithm
+type Algorithm string
+
+const (
+	// RS256 is RSASSA-PKCS1-v1_5 with SHA-256
+	RS256 Algorithm = "RS256"
+	// RS512 is RSASSA-PKCS1-v1_5 with SHA-512
+	RS512 Algorithm = "RS512"
+	// PS256 is RSASSA-PSS with SHA-256
+	PS256 Algorithm = "PS256"
+	// ES256 is ECDSA on P-256 with SHA-256
+	ES256 Algorithm = "ES256"
+	// ES384 is ECDSA on P-384 with SHA-384
+	ES384 Algorithm = "ES384"
+	// ES512 is ECDSA on P-521 with SHA-512
+	ES512 Algorithm = "ES512"
+	// EdDSA is Ed25519
+	EdDSA Algorithm = "EdDSA"
+	// HS256 is HMAC with SHA-256
+	HS256 Algorithm = "HS256"
+)
+
+// jwtHeader is the JOSE header of a token
+type jwtHeader struct {
+	Algorithm Algorithm `json:"alg"`
+	Type      string    `json:"typ,omitempty"`
+	KeyID     string    `json:"kid,omitempty"`
+}
+
+// JWT issues and verifies JWS compact tokens using the keys of a keystore.
+// The kid header holds the id of the key version, so tokens stay verifiable after key rotation
+type JWT struct {
+	store  *crypto.KeyStore
+	config Config
+}
+
+// NewJWT Creates a new JWT issuer and verifier with the default config. Tokens are verified with the named keys
+func NewJWT(store *crypto.KeyStore, keyNames ...string) *JWT {
+	config := DefaultConfig
+	config.KeyNames = keyNames
+	return NewJWTWithConfig(store, config)
+}
+
+// NewJWTWithConfig Creates a new JWT issuer and verifier with the config
+func NewJWTWithConfig(store *crypto.KeyStore, config Config) *JWT {
+	return &JWT{
+		store:  store,
+		config: config,
+	}
+}
+
+// Issue signs the claims with the active version of the named key. iat, exp and iss are filled from the config if unset
+func (j *JWT) Issue(keyName string, alg Algorithm, claims RegisteredClaims) (string, error) {
+	version, ok := j.store.ActiveKeyVersion(keyName)
+	if !ok {
+		return "", crypto.ErrNoActiveKey
+	}
+	j.config.prepare(claims.Registered())
+	return SignJWT(version.Key.Private(), version.ID, alg, claims)
+}
+
+// Verify verifies the token signature with the key version named by the kid header, decodes the claims
+// and validates them. Only versions of the keys in KeyNames are used and retired versions are rejected
+func (j *JWT) Verify(token string, claims RegisteredClaims) error {
+	return VerifyJWT(token, j.resolveKey, claims, j.config)
+}
+
+// resolveKey looks up the usable key version with the given id
+func (j *JWT) resolveKey(keyID string) (gocrypto.PublicKey, error) {
+	version, ok := findKeyVersion(j.store, j.config.KeyNames, keyID)
+	if !ok {
+		return nil, ErrTokenUnknownKey
+	}
+	if version.Key.Type() == crypto.KeyTypeSecret {
+		return version.Key.Private(), nil
+	}
+	return version.Key.Public(), nil
+}
+
+// findKeyVersion looks up the usable key version with the given id among the named keys. Keys that serve
+// other purposes in a shared keystore can not verify tokens
+func findKeyVersion(store *crypto.KeyStore, keyNames []string, keyID string) (crypto.KeyVersion, bool) {
+	for _, keyName := range keyNames {
+		if version, ok := store.KeyVersion(keyName, keyID); ok {
+			return version, version.Usable()
+		}
+	}
+	return crypto.KeyVersion{}, false
+}
+
+// KeyResolver returns the verification key for a kid. Secret keys are returned as []byte
+type KeyResolver func(keyID string) (gocrypto.PublicKey, error)
+
+// SignJWT signs the claims into a JWS compact token. The key is a private key or a []byte secret for HS256
+func SignJWT(key gocrypto.PrivateKey, keyID string, alg Algorithm, claims interface{}) (string, error) {
+	header, err := json.Marshal(jwtHeader{Algorithm: alg, Type: "JWT", KeyID: keyID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature, err := sign(alg, key, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// VerifyJWT verifies the token with the key returned by the resolver, decodes the claims and validates them
+func VerifyJWT(token string, resolve KeyResolver, claims RegisteredClaims, config Config) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrTokenMalformed
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return ErrTokenMalformed
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return ErrTokenMalformed
+	}
+	if header.KeyID == "" {
+		return ErrTokenUnknownKey
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return ErrTokenMalformed
+	}
+
+	key, err := resolve(header.KeyID)
+	if err != nil {
+		return err
+	}
+	if err := verify(header.Algorithm, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ErrTokenMalformed
+	}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return ErrTokenMalformed
+	}
+	return Validate(claims.Registered(), config)
+}
+
+// sign creates the signature of the signing input
+func sign(alg Algorithm, key gocrypto.PrivateKey, signingInput []byte) ([]byte, error) {
+	switch alg {
+	case RS256, RS512, PS256:
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, crypto.ErrUnsupportedKeyType
+		}
+		hashFunc, hashID := rsaHash(alg)
+		if alg == PS256 {
+			return rsa.SignPSS(rand.Reader, rsaKey, hashID, digest(hashFunc, signingInput), &rsa.PSSOptions{
+				SaltLength: rsa.PSSSaltLengthEqualsHash,
+			})
+		}
+		return rsa.SignPKCS1v15(rand.Reader, rsaKey, hashID, digest(hashFunc, signingInput))
+	case ES256, ES384, ES512:
+		ecdsaKey, ok := key.(*ecdsa.PrivateKey)
+		if !ok || ecdsaKey.Curve.Params().BitSize != ecdsaCurveSize(alg) {
+			return nil, crypto.ErrUnsupportedKeyType
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, ecdsaKey, digest(ecdsaHash(alg), signingInput))
+		if err != nil {
+			return nil, err
+		}
+		size := (ecdsaCurveSize(alg) + 7) / 8
+		signature := make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+		return signature, nil
+	case EdDSA:
+		edKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, crypto.ErrUnsupportedKeyType
+		}
+		return ed25519.Sign(edKey, signingInput), nil
+	case HS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return nil, crypto.ErrUnsupportedKeyType
+		}
+		return crypto.HMAC(signingInput, secret, sha256.New), nil
+	default:
+		return nil, ErrTokenUnsupportedAlgorithm
+	}
+}
+
+// verify checks the signature of the signing input. The key type must match the algorithm,
+// so a token can never pick a weaker verification than the key was made for
+func verify(alg Algorithm, key gocrypto.PublicKey, signingInput, signature []byte) error {
+	switch alg {
+	case RS256, RS512, PS256:
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrTokenUnsupportedAlgorithm
+		}
+		hashFunc, hashID := rsaHash(alg)
+		var err error
+		if alg == PS256 {
+			err = rsa.VerifyPSS(rsaKey, hashID, digest(hashFunc, signingInput), signature, &rsa.PSSOptions{
+				SaltLength: rsa.PSSSaltLengthEqualsHash,
+			})
+		} else {
+			err = rsa.VerifyPKCS1v15(rsaKey, hashID, digest(hashFunc, signingInput), signature)
+		}
+		if err != nil {
+			return ErrTokenInvalidSignature
+		}
+		return nil
+	case ES256, ES384, ES512:
+		ecdsaKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || ecdsaKey.Curve.Params().BitSize != ecdsaCurveSize(alg) {
+			return ErrTokenUnsupportedAlgorithm
+		}
+		size := (ecdsaCurveSize(alg) + 7) / 8
+		if len(signature) != 2*size {
+			return ErrTokenInvalidSignature
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(ecdsaKey, digest(ecdsaHash(alg), signingInput), r, s) {
+			return ErrTokenInvalidSignature
+		}
+		return nil
+	case EdDSA:
+		edKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return ErrTokenUnsupportedAlgorithm
+		}
+		if !ed25519.Verify(edKey, signingInput, signature) {
+			return ErrTokenInvalidSignature
+		}
+		return nil
+	case HS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return ErrTokenUnsupportedAlgorithm
+		}
+		if !crypto.ValidMAC(signingInput, secret, signature, sha256.New) {
+			return ErrTokenInvalidSignature
+		}
+		return nil
+	default:
+		return ErrTokenUnsupportedAlgorithm
+	}
+}
+
+// rsaHash returns the hash used by a rsa algorithm
+func rsaHash(alg Algorithm) (func() hash.Hash, gocrypto.Hash) {
+	if alg == RS512 {
+		return sha512.New, gocrypto.SHA512
+	}
+	return sha256.New, gocrypto.SHA256
+}
+
+// ecdsaHash returns the hash used by an ecdsa algorithm
+func ecdsaHash(alg Algorithm) func() hash.Hash {
+	switch alg {
+	case ES384:
+		return sha512.New384
+	case ES512:
+		return sha512.New
+	default:
+		return sha256.New
+	}
+}
+
+// ecdsaCurveSize returns the curve size in bits required by an ecdsa algorithm
+func ecdsaCurveSize(alg Algorithm) int {
+	switch alg {
+	case ES384:
+		return 384
+	case ES512:
+		return 521
+	default:
+		return 256
+	}
+}
+
+// digest hashes the message
+func digest(hashFunc func() hash.Hash, msg []byte) []byte {
+	h := hashFunc()
+	h.Write(msg)
+	return h.Sum(nil)
+}
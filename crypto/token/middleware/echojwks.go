@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/adityak368/swissknife/crypto"
+	"github.com/adityak368/swissknife/crypto/token"
+	"github.com/labstack/echo/v4"
+)
+
+// JWKSPath is the well known path of the json web key set
+const JWKSPath = "/.well-known/jwks.json"
+
+// EchoJWKSConfig defines the config for the json web key set handler
+type EchoJWKSConfig struct {
+	// MaxAge is sent in the Cache-Control header. It should be shorter than the time between rotating and retiring a key
+	MaxAge time.Duration
+	// KeyNames are the signing keys of the keystore that are published
+	KeyNames []string
+}
+
+// DefaultEchoJWKSConfig defines the default json web key set handler config
+var DefaultEchoJWKSConfig = EchoJWKSConfig{
+	MaxAge: 5 * time.Minute,
+}
+
+// EchoJWKS returns an echo handler serving the public keys of the named signing keys as a json web key set
+func EchoJWKS(store *crypto.KeyStore, keyNames ...string) echo.HandlerFunc {
+	config := DefaultEchoJWKSConfig
+	config.KeyNames = keyNames
+	return EchoJWKSWithConfig(store, config)
+}
+
+// EchoJWKSWithConfig returns an echo handler serving the public keys of the keystore with config.
+// The key set is built on every request, so rotated keys are published immediately
+func EchoJWKSWithConfig(store *crypto.KeyStore, config EchoJWKSConfig) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		jwks, err := token.NewJWKS(store, config.KeyNames...)
+		if err != nil {
+			return err
+		}
+		c.Response().Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(config.MaxAge.Seconds())))
+		return c.JSON(http.StatusOK, jwks)
+	}
+}
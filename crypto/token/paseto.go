@@ -0,0 +1,348 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/adityak368/swissknife/crypto"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20"
+)
+
+// PASETO v4 headers
+const (
+	pasetoLocalHeader  = "v4.local."
+	pasetoPublicHeader = "v4.public."
+)
+
+// pasetoKeySize is the size of v4.local keys
+const pasetoKeySize = 32
+
+// pasetoFooter is the footer of tokens issued with keystore keys
+type pasetoFooter struct {
+	KeyID string `json:"kid"`
+}
+
+// Paseto issues and verifies PASETO v4 tokens using the keys of a keystore. Secret keys of 32 bytes issue
+// encrypted v4.local tokens and ed25519 keys issue signed v4.public tokens. The footer holds the id of the
+// key version, so tokens stay verifiable after key rotation
+type Paseto struct {
+	store  *crypto.KeyStore
+	config Config
+}
+
+// NewPaseto Creates a new PASETO issuer and verifier with the default config. Tokens are verified with the named keys
+func NewPaseto(store *crypto.KeyStore, keyNames ...string) *Paseto {
+	config := DefaultConfig
+	config.KeyNames = keyNames
+	return NewPasetoWithConfig(store, config)
+}
+
+// NewPasetoWithConfig Creates a new PASETO issuer and verifier with the config
+func NewPasetoWithConfig(store *crypto.KeyStore, config Config) *Paseto {
+	return &Paseto{
+		store:  store,
+		config: config,
+	}
+}
+
+// Issue encrypts or signs the claims with the active version of the named key. iat, exp and iss are filled from
+// the config if unset. The implicit assertion is authenticated but not part of the token, Ex: a user id the
+// token is bound to. It must be passed again on verification
+func (p *Paseto) Issue(keyName string, claims RegisteredClaims, implicit []byte) (string, error) {
+	version, ok := p.store.ActiveKeyVersion(keyName)
+	if !ok {
+		return "", crypto.ErrNoActiveKey
+	}
+	p.config.prepare(claims.Registered())
+	payload, err := marshalPasetoClaims(claims)
+	if err != nil {
+		return "", err
+	}
+	footer, err := json.Marshal(pasetoFooter{KeyID: version.ID})
+	if err != nil {
+		return "", err
+	}
+
+	switch key := version.Key.Private().(type) {
+	case []byte:
+		return EncryptPasetoV4(key, payload, footer, implicit)
+	case ed25519.PrivateKey:
+		return SignPasetoV4(key, payload, footer, implicit)
+	default:
+		return "", crypto.ErrUnsupportedKeyType
+	}
+}
+
+// Verify decrypts or verifies the token with the key version named by the footer, decodes the claims and validates
+// them. Only versions of the keys in KeyNames are used. It implements the Verifier interface for tokens without
+// an implicit assertion
+func (p *Paseto) Verify(token string, claims RegisteredClaims) error {
+	return p.VerifyWithImplicit(token, claims, nil)
+}
+
+// VerifyWithImplicit verifies a token that was issued with the implicit assertion
+func (p *Paseto) VerifyWithImplicit(token string, claims RegisteredClaims, implicit []byte) error {
+	footerJSON, err := PasetoFooter(token)
+	if err != nil {
+		return err
+	}
+	var footer pasetoFooter
+	if err := json.Unmarshal(footerJSON, &footer); err != nil || footer.KeyID == "" {
+		return ErrTokenUnknownKey
+	}
+	version, ok := findKeyVersion(p.store, p.config.KeyNames, footer.KeyID)
+	if !ok {
+		return ErrTokenUnknownKey
+	}
+
+	var payload []byte
+	switch {
+	case strings.HasPrefix(token, pasetoLocalHeader):
+		key, ok := version.Key.Private().([]byte)
+		if !ok {
+			return ErrTokenUnsupportedAlgorithm
+		}
+		payload, _, err = DecryptPasetoV4(key, token, implicit)
+	case strings.HasPrefix(token, pasetoPublicHeader):
+		key, ok := version.Key.Public().(ed25519.PublicKey)
+		if !ok {
+			return ErrTokenUnsupportedAlgorithm
+		}
+		payload, _, err = VerifyPasetoV4(key, token, implicit)
+	default:
+		return ErrTokenUnsupportedAlgorithm
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := unmarshalPasetoClaims(payload, claims); err != nil {
+		return err
+	}
+	return Validate(claims.Registered(), p.config)
+}
+
+// EncryptPasetoV4 encrypts the payload into a v4.local token with a 32 byte key
+func EncryptPasetoV4(key, payload, footer, implicit []byte) (string, error) {
+	if len(key) != pasetoKeySize {
+		return "", crypto.ErrUnsupportedKeyType
+	}
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	encKey, counterNonce, authKey := pasetoLocalKeys(key, nonce)
+	cipher, err := chacha20.NewUnauthenticatedCipher(encKey, counterNonce)
+	if err != nil {
+		return "", err
+	}
+	ciphertext := make([]byte, len(payload))
+	cipher.XORKeyStream(ciphertext, payload)
+
+	tag := pasetoLocalTag(authKey, nonce, ciphertext, footer, implicit)
+	body := append(append(nonce, ciphertext...), tag...)
+	return encodePaseto(pasetoLocalHeader, body, footer), nil
+}
+
+// DecryptPasetoV4 authenticates and decrypts a v4.local token. It returns the payload and the footer
+func DecryptPasetoV4(key []byte, token string, implicit []byte) ([]byte, []byte, error) {
+	if len(key) != pasetoKeySize {
+		return nil, nil, ErrTokenUnsupportedAlgorithm
+	}
+	body, footer, err := decodePaseto(pasetoLocalHeader, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(body) < 64 {
+		return nil, nil, ErrTokenMalformed
+	}
+	nonce, ciphertext, tag := body[:32], body[32:len(body)-32], body[len(body)-32:]
+
+	encKey, counterNonce, authKey := pasetoLocalKeys(key, nonce)
+	if subtle.ConstantTimeCompare(tag, pasetoLocalTag(authKey, nonce, ciphertext, footer, implicit)) != 1 {
+		return nil, nil, ErrTokenInvalidSignature
+	}
+	cipher, err := chacha20.NewUnauthenticatedCipher(encKey, counterNonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	payload := make([]byte, len(ciphertext))
+	cipher.XORKeyStream(payload, ciphertext)
+	return payload, footer, nil
+}
+
+// SignPasetoV4 signs the payload into a v4.public token
+func SignPasetoV4(key ed25519.PrivateKey, payload, footer, implicit []byte) (string, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return "", crypto.ErrUnsupportedKeyType
+	}
+	signature := ed25519.Sign(key, pae([]byte(pasetoPublicHeader), payload, footer, implicit))
+	return encodePaseto(pasetoPublicHeader, append(append([]byte{}, payload...), signature...), footer), nil
+}
+
+// VerifyPasetoV4 verifies a v4.public token. It returns the payload and the footer
+func VerifyPasetoV4(key ed25519.PublicKey, token string, implicit []byte) ([]byte, []byte, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, nil, ErrTokenUnsupportedAlgorithm
+	}
+	body, footer, err := decodePaseto(pasetoPublicHeader, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(body) < ed25519.SignatureSize {
+		return nil, nil, ErrTokenMalformed
+	}
+	payload, signature := body[:len(body)-ed25519.SignatureSize], body[len(body)-ed25519.SignatureSize:]
+	if !ed25519.Verify(key, pae([]byte(pasetoPublicHeader), payload, footer, implicit), signature) {
+		return nil, nil, ErrTokenInvalidSignature
+	}
+	return payload, footer, nil
+}
+
+// PasetoFooter returns the footer of a token without verifying it. Use it only to select the verification key
+func PasetoFooter(token string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) < 3 || len(parts) > 4 {
+		return nil, ErrTokenMalformed
+	}
+	if len(parts) == 3 {
+		return nil, nil
+	}
+	footer, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	return footer, nil
+}
+
+// pasetoLocalKeys derives the encryption key, the XChaCha20 nonce and the authentication key from the key and the nonce
+func pasetoLocalKeys(key, nonce []byte) (encKey, counterNonce, authKey []byte) {
+	tmp := keyedBlake2b(56, key, []byte("paseto-encryption-key"), nonce)
+	return tmp[:32], tmp[32:], keyedBlake2b(32, key, []byte("paseto-auth-key-for-aead"), nonce)
+}
+
+// pasetoLocalTag returns the authentication tag of a v4.local token
+func pasetoLocalTag(authKey, nonce, ciphertext, footer, implicit []byte) []byte {
+	return keyedBlake2b(32, authKey, pae([]byte(pasetoLocalHeader), nonce, ciphertext, footer, implicit))
+}
+
+// keyedBlake2b returns the keyed BLAKE2b hash of the concatenated messages
+func keyedBlake2b(size int, key []byte, msgs ...[]byte) []byte {
+	h, err := blake2b.New(size, key)
+	if err != nil {
+		// Only returned for sizes and keys that are out of range, which are constants here
+		panic(err)
+	}
+	for _, msg := range msgs {
+		h.Write(msg)
+	}
+	return h.Sum(nil)
+}
+
+// pae is the pre-authentication encoding of PASETO. Every piece is prefixed with its length,
+// so pieces can not be shifted into each other
+func pae(pieces ...[]byte) []byte {
+	le64 := func(n int) []byte {
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, uint64(n)&(1<<63-1))
+		return b
+	}
+	out := le64(len(pieces))
+	for _, piece := range pieces {
+		out = append(out, le64(len(piece))...)
+		out = append(out, piece...)
+	}
+	return out
+}
+
+// encodePaseto returns header.base64(body)[.base64(footer)]
+func encodePaseto(header string, body, footer []byte) string {
+	token := header + base64.RawURLEncoding.EncodeToString(body)
+	if len(footer) > 0 {
+		token += "." + base64.RawURLEncoding.EncodeToString(footer)
+	}
+	return token
+}
+
+// decodePaseto checks the header and decodes the body and the footer
+func decodePaseto(header, token string) ([]byte, []byte, error) {
+	if !strings.HasPrefix(token, header) {
+		return nil, nil, ErrTokenUnsupportedAlgorithm
+	}
+	parts := strings.Split(token[len(header):], ".")
+	if len(parts) > 2 {
+		return nil, nil, ErrTokenMalformed
+	}
+	body, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, ErrTokenMalformed
+	}
+	var footer []byte
+	if len(parts) == 2 {
+		if footer, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+			return nil, nil, ErrTokenMalformed
+		}
+	}
+	return body, footer, nil
+}
+
+// pasetoTimeClaims are the claims PASETO encodes as RFC 3339 strings instead of unix timestamps
+var pasetoTimeClaims = []string{"exp", "nbf", "iat"}
+
+// marshalPasetoClaims encodes the claims as json with RFC 3339 time claims
+func marshalPasetoClaims(claims RegisteredClaims) ([]byte, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, err
+	}
+	for _, name := range pasetoTimeClaims {
+		var unix int64
+		if raw, ok := fields[name]; ok && json.Unmarshal(raw, &unix) == nil {
+			fields[name], _ = json.Marshal(time.Unix(unix, 0).UTC().Format(time.RFC3339))
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// unmarshalPasetoClaims decodes json with RFC 3339 time claims into the claims
+func unmarshalPasetoClaims(payload []byte, claims RegisteredClaims) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return ErrTokenMalformed
+	}
+	for _, name := range pasetoTimeClaims {
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return ErrTokenMalformed
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return ErrTokenMalformed
+		}
+		fields[name], _ = json.Marshal(t.Unix())
+	}
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return ErrTokenMalformed
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package token
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/adityak368/swissknife/response"
+)
+
+// Errors returned when a token is rejected. They are response errors, so the message id can be translated
+var (
+	ErrTokenMalformed            = response.NewError(http.StatusUnauthorized, "TokenMalformed")
+	ErrTokenUnsupportedAlgorithm = response.NewError(http.StatusUnauthorized, "TokenUnsupportedAlgorithm")
+	ErrTokenUnknownKey           = response.NewError(http.StatusUnauthorized, "TokenUnknownKey")
+	ErrTokenInvalidSignature     = response.NewError(http.StatusUnauthorized, "TokenInvalidSignature")
+	ErrTokenExpired              = response.NewError(http.StatusUnauthorized, "TokenExpired")
+	ErrTokenMissingExpiry        = response.NewError(http.StatusUnauthorized, "TokenMissingExpiry")
+	ErrTokenNotYetValid          = response.NewError(http.StatusUnauthorized, "TokenNotYetValid")
+	ErrTokenIssuedInFuture       = response.NewError(http.StatusUnauthorized, "TokenIssuedInFuture")
+	ErrTokenInvalidIssuer        = response.NewError(http.StatusUnauthorized, "TokenInvalidIssuer")
+	ErrTokenInvalidAudience      = response.NewError(http.StatusUnauthorized, "TokenInvalidAudience")
+)
+
+// Claims defines the registered claims of a token. Embed it into a struct to add custom claims
+type Claims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+}
+
+// RegisteredClaims is implemented by every struct that embeds Claims
+type RegisteredClaims interface {
+	Registered() *Claims
+}
+
+// Registered implements the RegisteredClaims interface
+func (c *Claims) Registered() *Claims {
+	return c
+}
+
+// Audience is the aud claim. It is a single string or a list of strings in json
+type Audience []string
+
+// MarshalJSON encodes a single audience as a string
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// UnmarshalJSON accepts a string or a list of strings
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = Audience(list)
+	return nil
+}
+
+// Contains reports whether the audience contains the given value
+func (a Audience) Contains(audience string) bool {
+	for _, value := range a {
+		if value == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// Config defines the config for issuing and validating tokens
+type Config struct {
+	// Issuer is set as iss on issued tokens that have none and is required on validated tokens if not empty
+	Issuer string
+	// Audience is required in the aud claim of validated tokens if not empty
+	Audience string
+	// TTL is used to set exp on issued tokens that have none. Zero leaves exp unset
+	TTL time.Duration
+	// ClockSkew is the tolerance used when validating exp, nbf and iat
+	ClockSkew time.Duration
+	// AllowMissingExpiry accepts tokens without an exp claim
+	AllowMissingExpiry bool
+	// Now returns the current time. Defaults to time.Now
+	Now func() time.Time
+	// KeyNames are the keystore keys tokens are verified with. Tokens of any other key in the keystore are rejected
+	KeyNames []string
+}
+
+// DefaultConfig defines the default token config
+var DefaultConfig = Config{
+	TTL:       time.Hour,
+	ClockSkew: time.Minute,
+	Now:       time.Now,
+}
+
+// now returns the current time of the config
+func (config *Config) now() time.Time {
+	if config.Now == nil {
+		return time.Now()
+	}
+	return config.Now()
+}
+
+// prepare fills the registered claims of a token that is about to be issued
+func (config *Config) prepare(claims *Claims) {
+	now := config.now()
+	if claims.IssuedAt == 0 {
+		claims.IssuedAt = now.Unix()
+	}
+	if claims.ExpiresAt == 0 && config.TTL > 0 {
+		claims.ExpiresAt = now.Add(config.TTL).Unix()
+	}
+	if claims.Issuer == "" {
+		claims.Issuer = config.Issuer
+	}
+}
+
+// Validate checks the time based claims, the issuer and the audience against the config
+func Validate(claims *Claims, config Config) error {
+	now := config.now()
+	skew := config.ClockSkew
+
+	if claims.ExpiresAt == 0 {
+		if !config.AllowMissingExpiry {
+			return ErrTokenMissingExpiry
+		}
+	} else if !now.Before(time.Unix(claims.ExpiresAt, 0).Add(skew)) {
+		return ErrTokenExpired
+	}
+	if claims.NotBefore != 0 && now.Add(skew).Before(time.Unix(claims.NotBefore, 0)) {
+		return ErrTokenNotYetValid
+	}
+	if claims.IssuedAt != 0 && now.Add(skew).Before(time.Unix(claims.IssuedAt, 0)) {
+		return ErrTokenIssuedInFuture
+	}
+	if config.Issuer != "" && claims.Issuer != config.Issuer {
+		return ErrTokenInvalidIssuer
+	}
+	if config.Audience != "" && !claims.Audience.Contains(config.Audience) {
+		return ErrTokenInvalidAudience
+	}
+	return nil
+}
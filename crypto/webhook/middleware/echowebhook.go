@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/adityak368/swissknife/crypto/internal/echoerror"
+	"github.com/adityak368/swissknife/crypto/webhook"
+	"github.com/adityak368/swissknife/response"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// EchoWebhookConfig defines the config for the webhook verification middleware
+type EchoWebhookConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+	// Config is used to verify the signatures
+	Config webhook.Config
+	// Secrets returns the secrets a webhook may be signed with. Ex: webhook.KeyStoreSecrets
+	Secrets func() [][]byte
+	// MaxBodySize limits the size of the body that is read for verification
+	MaxBodySize int64
+	// ErrorHandler writes the response for a rejected request. Defaults to an untranslated response.Message.
+	// Ex: jwtauth.LocalizedErrorHandler
+	ErrorHandler func(c echo.Context, err *response.Error) error
+}
+
+// DefaultEchoWebhookConfig defines the default webhook verification middleware config
+var DefaultEchoWebhookConfig = EchoWebhookConfig{
+	Skipper:      middleware.DefaultSkipper,
+	Config:       webhook.DefaultConfig,
+	MaxBodySize:  1 << 20,
+	ErrorHandler: echoerror.Message,
+}
+
+// ErrMissingSecrets is returned when no Secrets function is configured
+var ErrMissingSecrets = errors.New("webhook middleware requires Secrets")
+
+// EchoWebhook returns a middleware for echo that verifies inbound webhooks signed with any of the secrets
+func EchoWebhook(secrets ...[]byte) echo.MiddlewareFunc {
+	config := DefaultEchoWebhookConfig
+	config.Secrets = func() [][]byte { return secrets }
+	return EchoWebhookWithConfig(config)
+}
+
+// EchoWebhookWithConfig returns a middleware for echo with config. The body is restored after verification,
+// so the handler can still bind it
+func EchoWebhookWithConfig(config EchoWebhookConfig) echo.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultEchoWebhookConfig.Skipper
+	}
+	if config.Secrets == nil {
+		panic(ErrMissingSecrets)
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = DefaultEchoWebhookConfig.MaxBodySize
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = DefaultEchoWebhookConfig.ErrorHandler
+	}
+	header := config.Config.Header
+	if header == "" {
+		header = webhook.DefaultConfig.Header
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			req := c.Request()
+			body, err := ioutil.ReadAll(http.MaxBytesReader(c.Response(), req.Body, config.MaxBodySize))
+			if err != nil {
+				return echo.ErrStatusRequestEntityTooLarge
+			}
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			if err := webhook.Verify(body, req.Header.Get(header), config.Config, config.Secrets()...); err != nil {
+				var responseError *response.Error
+				if errors.As(err, &responseError) {
+					return config.ErrorHandler(c, responseError)
+				}
+				return err
+			}
+			return next(c)
+		}
+	}
+}
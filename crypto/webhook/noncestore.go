@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"sync"
+	"time"
+)
+
+// NonceStore remembers received webhooks until their timestamp is outside of the tolerance
+type NonceStore interface {
+	// Use records the nonce until expiresAt. It returns false if the nonce is already recorded
+	Use(nonce string, expiresAt time.Time) (bool, error)
+}
+
+// MemoryNonceStore is a NonceStore for a single instance. Use a shared store, Ex: redis SET NX with an expiry,
+// when running multiple instances
+type MemoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+	now    func() time.Time
+}
+
+// NewMemoryNonceStore Creates a new in memory NonceStore
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{
+		nonces: map[string]time.Time{},
+		now:    time.Now,
+	}
+}
+
+// Use implements the NonceStore interface. Expired nonces are removed on every call
+func (s *MemoryNonceStore) Use(nonce string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for n, expiry := range s.nonces {
+		if !now.Before(expiry) {
+			delete(s.nonces, n)
+		}
+	}
+	if _, ok := s.nonces[nonce]; ok {
+		return false, nil
+	}
+	s.nonces[nonce] = expiresAt
+	return true, nil
+}
@@ -0,0 +1,167 @@
+package webhook
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adityak368/swissknife/crypto"
+	"github.com/adityak368/swissknife/response"
+)
+
+// Errors returned when an inbound webhook is rejected. They are response errors, so the message id can be translated
+var (
+	ErrSignatureMissing = response.NewError(http.StatusUnauthorized, "WebhookSignatureMissing")
+	ErrSignatureInvalid = response.NewError(http.StatusUnauthorized, "WebhookSignatureInvalid")
+	ErrTimestampInvalid = response.NewError(http.StatusUnauthorized, "WebhookTimestampInvalid")
+	ErrReplayed         = response.NewError(http.StatusUnauthorized, "WebhookReplayed")
+)
+
+// signatureVersion is the scheme of the signatures: HMAC-SHA256 of "timestamp.payload", hex encoded
+const signatureVersion = "v1"
+
+// Config defines the webhook config
+type Config struct {
+	// Header is the request header holding the signature. Its value is "t=<unix timestamp>,v1=<signature>[,v1=<signature>]"
+	Header string
+	// Tolerance is the maximum age of an inbound webhook, and how far its timestamp may be in the future
+	Tolerance time.Duration
+	// NonceStore rejects inbound webhooks that were already received. Replay protection is disabled if nil
+	NonceStore NonceStore
+	// Now returns the current time. Defaults to time.Now
+	Now func() time.Time
+}
+
+// DefaultConfig defines the default webhook config
+var DefaultConfig = Config{
+	Header:    "Webhook-Signature",
+	Tolerance: 5 * time.Minute,
+	Now:       time.Now,
+}
+
+// withDefaults fills the unset fields of the config from the default config
+func (config Config) withDefaults() Config {
+	if config.Header == "" {
+		config.Header = DefaultConfig.Header
+	}
+	if config.Tolerance <= 0 {
+		config.Tolerance = DefaultConfig.Tolerance
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return config
+}
+
+// Sign returns the signature header value of the payload. One signature is added per secret,
+// so receivers keep accepting webhooks while a secret is rotated
+func Sign(payload []byte, timestamp time.Time, secrets ...[]byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	msg := signedMessage(t, payload)
+
+	var b strings.Builder
+	b.WriteString("t=" + t)
+	for _, secret := range secrets {
+		b.WriteString("," + signatureVersion + "=")
+		b.WriteString(hex.EncodeToString(crypto.HMAC(msg, secret, sha256.New)))
+	}
+	return b.String()
+}
+
+// SignRequest sets the signature header of an outbound webhook request. The payload must be the request body
+func SignRequest(req *http.Request, payload []byte, config Config, secrets ...[]byte) {
+	config = config.withDefaults()
+	req.Header.Set(config.Header, Sign(payload, config.Now(), secrets...))
+}
+
+// Verify checks the signature header value of an inbound webhook against all secrets, rejects timestamps
+// outside of the tolerance and, if a nonce store is configured, webhooks that were already received
+func Verify(payload []byte, header string, config Config, secrets ...[]byte) error {
+	config = config.withDefaults()
+	if header == "" {
+		return ErrSignatureMissing
+	}
+
+	var timestamp string
+	var signatures [][]byte
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case signatureVersion:
+			if signature, err := hex.DecodeString(kv[1]); err == nil {
+				signatures = append(signatures, signature)
+			}
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrTimestampInvalid
+	}
+	if len(signatures) == 0 {
+		return ErrSignatureMissing
+	}
+
+	if !validSignature(signedMessage(timestamp, payload), signatures, secrets) {
+		return ErrSignatureInvalid
+	}
+
+	sentAt := time.Unix(unix, 0)
+	age := config.Now().Sub(sentAt)
+	if age > config.Tolerance || age < -config.Tolerance {
+		return ErrTimestampInvalid
+	}
+
+	if config.NonceStore != nil {
+		// The nonce only depends on signed data, so stripping one of multiple signatures does not create a new nonce
+		sum := sha256.Sum256(payload)
+		ok, err := config.NonceStore.Use(timestamp+"."+hex.EncodeToString(sum[:]), sentAt.Add(config.Tolerance))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrReplayed
+		}
+	}
+	return nil
+}
+
+// KeyStoreSecrets returns the secrets of all usable versions of the named secret key, newest first.
+// Use it to verify inbound webhooks while rotating a secret shared with the sender
+func KeyStoreSecrets(store *crypto.KeyStore, keyName string) [][]byte {
+	versions := store.KeyVersions(keyName)
+	secrets := make([][]byte, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].Usable() && versions[i].Key.Type() == crypto.KeyTypeSecret {
+			secrets = append(secrets, versions[i].Key.Private().([]byte))
+		}
+	}
+	return secrets
+}
+
+// signedMessage returns the message that is signed
+func signedMessage(timestamp string, payload []byte) []byte {
+	msg := make([]byte, 0, len(timestamp)+1+len(payload))
+	msg = append(msg, timestamp...)
+	msg = append(msg, '.')
+	return append(msg, payload...)
+}
+
+// validSignature reports whether any signature is valid for any secret
+func validSignature(msg []byte, signatures, secrets [][]byte) bool {
+	for _, secret := range secrets {
+		for _, signature := range signatures {
+			if crypto.ValidMAC(msg, secret, signature, sha256.New) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// X25519KeySize is the size of x25519 private and public keys
+const X25519KeySize = curve25519.ScalarSize
+
+// ErrInvalidX25519Key is returned when a x25519 key has the wrong size or the peer key is a low order point
+var ErrInvalidX25519Key = errors.New("invalid x25519 key")
+
+// GenerateX25519KeyPair generates a pub/priv x25519 key pair for key agreement
+func GenerateX25519KeyPair() (publicKey, privateKey []byte, err error) {
+	privateKey = make([]byte, X25519KeySize)
+	if _, err := rand.Read(privateKey); err != nil {
+		return nil, nil, err
+	}
+	publicKey, err = X25519PublicKey(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return publicKey, privateKey, nil
+}
+
+// X25519PublicKey returns the public key of a x25519 private key
+func X25519PublicKey(privateKey []byte) ([]byte, error) {
+	if len(privateKey) != X25519KeySize {
+		return nil, ErrInvalidX25519Key
+	}
+	return curve25519.X25519(privateKey, curve25519.Basepoint)
+}
+
+// X25519SharedKey derives a 32 byte key shared with the peer from the x25519 exchange using HKDF-SHA512.
+// Both public keys are bound into the key, so both peers derive the same key for the same info
+func X25519SharedKey(privateKey, peerPublicKey, info []byte) ([]byte, error) {
+	if len(privateKey) != X25519KeySize || len(peerPublicKey) != X25519KeySize {
+		return nil, ErrInvalidX25519Key
+	}
+	publicKey, err := X25519PublicKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := curve25519.X25519(privateKey, peerPublicKey)
+	if err != nil {
+		// The peer key is a low order point
+		return nil, ErrInvalidX25519Key
+	}
+
+	// The salt holds both public keys in a fixed order, so it does not depend on which side derives the key
+	salt := make([]byte, 0, 2*X25519KeySize)
+	if bytes.Compare(publicKey, peerPublicKey) < 0 {
+		salt = append(append(salt, publicKey...), peerPublicKey...)
+	} else {
+		salt = append(append(salt, peerPublicKey...), publicKey...)
+	}
+	return deriveKey(secret, salt, append([]byte("skx25519"), info...), symmKeySize)
+}
+
+// EncryptForPeer encrypts the message with the key shared between the private key and the peers public key.
+// Only the peer can decrypt it and the peer knows that it was encrypted by the owner of the private key.
+// The senders public key is authenticated, so a message can not be reflected back to its sender
+func EncryptForPeer(msg, privateKey, peerPublicKey []byte, config SymmKeyConfig) ([]byte, error) {
+	key, err := X25519SharedKey(privateKey, peerPublicKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	senderPublicKey, err := X25519PublicKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	config.AssociatedData = symmAssociatedData(senderPublicKey, config.AssociatedData)
+	return EncryptUsingSymmKeyWithConfig(msg, key, config)
+}
+
+// DecryptFromPeer decrypts a message encrypted by the peer with EncryptForPeer
+func DecryptFromPeer(encryptedMsg, privateKey, peerPublicKey []byte, config SymmKeyConfig) ([]byte, error) {
+	key, err := X25519SharedKey(privateKey, peerPublicKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	config.AssociatedData = symmAssociatedData(peerPublicKey, config.AssociatedData)
+	return DecryptUsingSymmKeyWithConfig(encryptedMsg, key, config)
+}
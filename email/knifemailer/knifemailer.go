@@ -1,7 +1,10 @@
 package knifemailer
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"io"
 
 	"github.com/adityak368/swissknife/email"
 	"github.com/adityak368/swissknife/logger/v2"
@@ -36,9 +39,53 @@ func (m *knifeMailer) SendMail(from, to, subject, body string) error {
 	msg.SetHeader("To", to)
 	msg.SetHeader("Subject", subject)
 	msg.SetBody("text/html", body)
-	//m.SetAddressHeader("Cc", "dan@example.com", "Dan")
-	//m.Attach("/home/Alex/lolcat.jpg")
+	return m.enqueue(msg)
+}
+
+// Send sends a message. This is thread safe
+func (m *knifeMailer) Send(ctx context.Context, message *email.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := message.Validate(); err != nil {
+		return err
+	}
+
+	msg := gomail.NewMessage()
+	msg.SetAddressHeader("From", message.From.Email, message.From.Name)
+	setAddresses(msg, "To", message.To)
+	setAddresses(msg, "Cc", message.Cc)
+	setAddresses(msg, "Bcc", message.Bcc)
+	setAddresses(msg, "Reply-To", message.ReplyTo)
+	msg.SetHeader("Subject", message.Subject)
+	for name, value := range message.Headers {
+		msg.SetHeader(name, value)
+	}
 
+	// The last alternative is the preferred one, so html comes after text
+	switch {
+	case message.TextBody != "" && message.HTMLBody != "":
+		msg.SetBody("text/plain", message.TextBody)
+		msg.AddAlternative("text/html", message.HTMLBody)
+	case message.HTMLBody != "":
+		msg.SetBody("text/html", message.HTMLBody)
+	default:
+		msg.SetBody("text/plain", message.TextBody)
+	}
+
+	for _, attachment := range message.Attachments {
+		name, settings := attachmentSettings(attachment)
+		if attachment.ContentID != "" {
+			msg.Embed(name, settings...)
+		} else {
+			msg.Attach(name, settings...)
+		}
+	}
+	return m.enqueue(msg)
+}
+
+// enqueue hands the message to the daemon
+func (m *knifeMailer) enqueue(msg *gomail.Message) error {
 	// Send to the channel in a select as the thread might be blocked if buffer is full
 	select {
 	case m.sendMailChannel <- msg:
@@ -48,6 +95,46 @@ func (m *knifeMailer) SendMail(from, to, subject, body string) error {
 	return nil
 }
 
+// setAddresses sets an address list header
+func setAddresses(msg *gomail.Message, field string, addresses []email.Address) {
+	if len(addresses) == 0 {
+		return
+	}
+	values := make([]string, len(addresses))
+	for i, address := range addresses {
+		values[i] = msg.FormatAddress(address.Email, address.Name)
+	}
+	msg.SetHeader(field, values...)
+}
+
+// attachmentSettings returns the name and the gomail settings of an attachment
+func attachmentSettings(attachment email.Attachment) (string, []gomail.FileSetting) {
+	name := attachment.Path
+	var settings []gomail.FileSetting
+	if attachment.Path == "" {
+		name = attachment.Filename
+		content := attachment.Content
+		settings = append(settings, gomail.SetCopyFunc(func(w io.Writer) error {
+			_, err := io.Copy(w, bytes.NewReader(content))
+			return err
+		}))
+	} else if attachment.Filename != "" {
+		settings = append(settings, gomail.Rename(attachment.Filename))
+	}
+
+	header := map[string][]string{}
+	if attachment.ContentType != "" {
+		header["Content-Type"] = []string{attachment.ContentType}
+	}
+	if attachment.ContentID != "" {
+		header["Content-ID"] = []string{"<" + attachment.ContentID + ">"}
+	}
+	if len(header) > 0 {
+		settings = append(settings, gomail.SetHeader(header))
+	}
+	return name, settings
+}
+
 // StartDaemon Starts the Email daemon
 func (m *knifeMailer) StartDaemon() error {
 	dialer := gomail.NewDialer(m.config.Host, m.config.Port, m.config.Username, m.config.Password)
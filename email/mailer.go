@@ -1,8 +1,13 @@
 package email
 
+import "context"
+
 // Mailer defines the emailer interface
 type Mailer interface {
+	// SendMail sends a html email to a single recipient
 	SendMail(from, to, subject, body string) error
+	// Send sends a message with multiple recipients, alternative bodies and attachments
+	Send(ctx context.Context, msg *Message) error
 	StartDaemon() error
 	StopDaemon() error
 }
@@ -0,0 +1,65 @@
+package mailtemplate
+
+import (
+	"context"
+	"errors"
+
+	"github.com/adityak368/swissknife/email"
+)
+
+// ErrMissingEnvelope is returned when SendTemplateWithEnvelope is called without an envelope
+var ErrMissingEnvelope = errors.New("email template envelope is missing")
+
+// Recipient is implemented by template data that knows who the email is sent to, Ex: a user
+type Recipient interface {
+	EmailAddress() email.Address
+}
+
+// Mailer is an email.Mailer that also sends templated emails
+type Mailer struct {
+	email.Mailer
+	renderer *Renderer
+}
+
+// NewMailer Creates a new templated mailer sending through the mailer
+func NewMailer(mailer email.Mailer, renderer *Renderer) *Mailer {
+	return &Mailer{
+		Mailer:   mailer,
+		renderer: renderer,
+	}
+}
+
+// SendTemplate renders the named email template in the locale and sends it with the envelope of the config.
+// If the data is a Recipient, the email is sent to its address in addition to the recipients of the envelope
+func (m *Mailer) SendTemplate(ctx context.Context, name, locale string, data interface{}) error {
+	envelope := m.renderer.config.Envelope
+	envelope.To = append([]email.Address(nil), envelope.To...)
+	if recipient, ok := data.(Recipient); ok {
+		envelope.To = append(envelope.To, recipient.EmailAddress())
+	}
+	return m.SendTemplateWithEnvelope(ctx, name, locale, data, &envelope)
+}
+
+// SendTemplateWithEnvelope renders the named email template in the locale and sends it. The envelope holds the
+// sender, the recipients, headers and attachments. Its subject and bodies are replaced by the rendered ones and
+// its sender defaults to the sender of the config envelope
+func (m *Mailer) SendTemplateWithEnvelope(ctx context.Context, name, locale string, data interface{}, envelope *email.Message) error {
+	if envelope == nil {
+		return ErrMissingEnvelope
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	rendered, err := m.renderer.Render(name, locale, data)
+	if err != nil {
+		return err
+	}
+	msg := *envelope
+	if msg.From.Email == "" {
+		msg.From = m.renderer.config.Envelope.From
+	}
+	msg.Subject = rendered.Subject
+	msg.HTMLBody = rendered.HTML
+	msg.TextBody = rendered.Text
+	return m.Send(ctx, &msg)
+}
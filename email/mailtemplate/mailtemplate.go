@@ -0,0 +1,275 @@
+package mailtemplate
+
+import (
+	"bytes"
+	"errors"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/adityak368/swissknife/email"
+	"github.com/adityak368/swissknife/localization"
+)
+
+// Errors returned when rendering templates
+var (
+	// ErrTemplateNotFound is returned when an email template has neither a html nor a text body
+	ErrTemplateNotFound = errors.New("email template not found")
+	// ErrSubjectNotFound is returned when an email template has no subject
+	ErrSubjectNotFound = errors.New("email template has no subject")
+)
+
+// File suffixes of the parts of an email template
+const (
+	subjectSuffix = ".subject.txt"
+	htmlSuffix    = ".html"
+	textSuffix    = ".txt"
+)
+
+// Config defines the email template config
+type Config struct {
+	// Localizer provides the translator of the Tr template function. Tr returns the key if nil
+	Localizer localization.Localizer
+	// LayoutsDir and PartialsDir hold templates that are available to every email template
+	LayoutsDir  string
+	PartialsDir string
+	// Funcs are additional template functions
+	Funcs map[string]interface{}
+	// Envelope is the default envelope of the emails sent by Mailer.SendTemplate, Ex: the sender and reply-to
+	// addresses. The recipients are added from the template data
+	Envelope email.Message
+}
+
+// DefaultConfig defines the default email template config
+var DefaultConfig = Config{
+	LayoutsDir:  "layouts",
+	PartialsDir: "partials",
+}
+
+// Rendered is a rendered email
+type Rendered struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Renderer renders emails from a folder of templates. An email template "welcome" consists of
+// welcome.subject.txt, welcome.html and welcome.txt, of which the html or the text body may be left out.
+// Localized variants are picked by locale, Ex: welcome.de.html for "de-AT" and "de".
+// .html files are parsed with html/template, .txt files with text/template. Templates can use
+// layouts and partials by their file name and translate with {{Tr "Key" args}}
+//
+//	layouts/base.html:  <html><body>{{template "content" .}}</body></html>
+//	welcome.html:       {{define "content"}}{{Tr "Welcome" .Name}}{{end}}{{template "base.html" .}}
+type Renderer struct {
+	config Config
+	html   map[string]*htmltemplate.Template
+	text   map[string]*texttemplate.Template
+}
+
+// New Creates a new renderer with the default config from a file system, Ex: an embed.FS or os.DirFS("templates")
+func New(fsys fs.FS) (*Renderer, error) {
+	return NewWithConfig(fsys, DefaultConfig)
+}
+
+// NewWithConfig Creates a new renderer with config. All templates are parsed immediately
+func NewWithConfig(fsys fs.FS, config Config) (*Renderer, error) {
+	if config.LayoutsDir == "" {
+		config.LayoutsDir = DefaultConfig.LayoutsDir
+	}
+	if config.PartialsDir == "" {
+		config.PartialsDir = DefaultConfig.PartialsDir
+	}
+
+	// Tr is replaced by the translator of the locale on every render
+	funcs := map[string]interface{}{
+		"Tr": func(key string, args ...interface{}) string { return key },
+	}
+	for name, fn := range config.Funcs {
+		funcs[name] = fn
+	}
+	htmlBase := htmltemplate.New("").Funcs(funcs)
+	textBase := texttemplate.New("").Funcs(funcs)
+
+	for _, dir := range []string{config.LayoutsDir, config.PartialsDir} {
+		files, err := fs.ReadDir(fsys, dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if file.IsDir() {
+				continue
+			}
+			if err := parseFile(fsys, path.Join(dir, file.Name()), htmlBase, textBase); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	r := &Renderer{
+		config: config,
+		html:   map[string]*htmltemplate.Template{},
+		text:   map[string]*texttemplate.Template{},
+	}
+	files, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() {
+			continue
+		}
+		src, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case strings.HasSuffix(name, htmlSuffix):
+			t, err := htmlBase.Clone()
+			if err != nil {
+				return nil, err
+			}
+			if r.html[name], err = t.New(name).Parse(string(src)); err != nil {
+				return nil, err
+			}
+		case strings.HasSuffix(name, textSuffix):
+			t, err := textBase.Clone()
+			if err != nil {
+				return nil, err
+			}
+			if r.text[name], err = t.New(name).Parse(string(src)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r, nil
+}
+
+// Render renders the subject and the bodies of the named email template in the locale
+func (r *Renderer) Render(name, locale string, data interface{}) (*Rendered, error) {
+	funcs := map[string]interface{}{"Tr": r.translate(locale)}
+	variants := localeVariants(name, locale)
+
+	rendered := &Rendered{}
+	subject, ok := r.text[findVariant(variants, subjectSuffix, r.hasText)]
+	if !ok {
+		return nil, ErrSubjectNotFound
+	}
+	var err error
+	if rendered.Subject, err = executeText(subject, funcs, data); err != nil {
+		return nil, err
+	}
+	// Line breaks are not allowed in the subject header
+	rendered.Subject = strings.Join(strings.Fields(rendered.Subject), " ")
+
+	if t, ok := r.html[findVariant(variants, htmlSuffix, r.hasHTML)]; ok {
+		t, err := t.Clone()
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := t.Funcs(funcs).Execute(&buf, data); err != nil {
+			return nil, err
+		}
+		rendered.HTML = buf.String()
+	}
+	if t, ok := r.text[findVariant(variants, textSuffix, r.hasText)]; ok {
+		if rendered.Text, err = executeText(t, funcs, data); err != nil {
+			return nil, err
+		}
+	}
+	if rendered.HTML == "" && rendered.Text == "" {
+		return nil, ErrTemplateNotFound
+	}
+	return rendered, nil
+}
+
+// translate returns the Tr function of the locale. Keys missing in a regional locale, Ex: "de-AT",
+// are looked up in its language, Ex: "de"
+func (r *Renderer) translate(locale string) func(key string, args ...interface{}) string {
+	if r.config.Localizer == nil {
+		return func(key string, args ...interface{}) string { return key }
+	}
+	translators := []localization.Translator{r.config.Localizer.Translator(locale)}
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		translators = append(translators, r.config.Localizer.Translator(locale[:i]))
+	}
+	return func(key string, args ...interface{}) string {
+		for _, translator := range translators {
+			if translated := translator.Tr(key, args...); translated != key {
+				return translated
+			}
+		}
+		return key
+	}
+}
+
+// hasHTML reports whether a html template file exists
+func (r *Renderer) hasHTML(file string) bool {
+	_, ok := r.html[file]
+	return ok
+}
+
+// hasText reports whether a text template file exists
+func (r *Renderer) hasText(file string) bool {
+	_, ok := r.text[file]
+	return ok
+}
+
+// executeText renders a text template with the functions
+func executeText(t *texttemplate.Template, funcs map[string]interface{}, data interface{}) (string, error) {
+	t, err := t.Clone()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Funcs(funcs).Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// parseFile adds a layout or partial to the html or text base template by its extension
+func parseFile(fsys fs.FS, file string, htmlBase *htmltemplate.Template, textBase *texttemplate.Template) error {
+	src, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return err
+	}
+	name := path.Base(file)
+	switch path.Ext(file) {
+	case htmlSuffix:
+		_, err = htmlBase.New(name).Parse(string(src))
+	case textSuffix:
+		_, err = textBase.New(name).Parse(string(src))
+	}
+	return err
+}
+
+// localeVariants returns the file name prefixes of a template from the most to the least specific locale,
+// Ex: welcome.de-AT, welcome.de, welcome
+func localeVariants(name, locale string) []string {
+	var variants []string
+	if locale != "" {
+		variants = append(variants, name+"."+locale)
+		if i := strings.IndexAny(locale, "-_"); i > 0 {
+			variants = append(variants, name+"."+locale[:i])
+		}
+	}
+	return append(variants, name)
+}
+
+// findVariant returns the first existing file of the variants with the suffix
+func findVariant(variants []string, suffix string, exists func(string) bool) string {
+	for _, variant := range variants {
+		if exists(variant + suffix) {
+			return variant + suffix
+		}
+	}
+	return ""
+}
@@ -0,0 +1,93 @@
+package email
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrNoSender is returned when a message has no From address
+	ErrNoSender = errors.New("email message has no sender")
+	// ErrNoRecipients is returned when a message has no To, Cc or Bcc address
+	ErrNoRecipients = errors.New("email message has no recipients")
+	// ErrNoBody is returned when a message has neither a html nor a text body
+	ErrNoBody = errors.New("email message has no body")
+	// ErrInvalidAttachment is returned when an attachment has no content or no file name
+	ErrInvalidAttachment = errors.New("email attachment needs a path or content and a file name")
+	// ErrInvalidHeader is returned when a header, subject or address contains a line break
+	ErrInvalidHeader = errors.New("email headers must not contain line breaks")
+)
+
+// Address defines an email address with an optional display name
+type Address struct {
+	Name  string
+	Email string
+}
+
+// Attachment defines a file attached to a message. Either Path or Content must be set
+type Attachment struct {
+	// Filename is the name shown to the recipient. It defaults to the base name of Path
+	Filename string
+	// Path is a file on disk that is read when the message is sent
+	Path string
+	// Content is the in memory content of the file
+	Content []byte
+	// ContentType is detected from the file name if empty
+	ContentType string
+	// ContentID embeds the file inline. Reference it from the html body as <img src="cid:ContentID">
+	ContentID string
+}
+
+// Message defines an email
+type Message struct {
+	From    Address
+	To      []Address
+	Cc      []Address
+	Bcc     []Address
+	ReplyTo []Address
+	Subject string
+	// HTMLBody and TextBody are sent as alternatives if both are set. Clients show the best one they support
+	HTMLBody string
+	TextBody string
+	// Headers are additional headers, Ex: List-Unsubscribe
+	Headers     map[string]string
+	Attachments []Attachment
+}
+
+// Validate checks that the message can be sent
+func (m *Message) Validate() error {
+	if m.From.Email == "" {
+		return ErrNoSender
+	}
+	if len(m.To)+len(m.Cc)+len(m.Bcc) == 0 {
+		return ErrNoRecipients
+	}
+	if m.HTMLBody == "" && m.TextBody == "" {
+		return ErrNoBody
+	}
+
+	values := []string{m.Subject, m.From.Name, m.From.Email}
+	for _, list := range [][]Address{m.To, m.Cc, m.Bcc, m.ReplyTo} {
+		for _, address := range list {
+			values = append(values, address.Name, address.Email)
+		}
+	}
+	for name, value := range m.Headers {
+		values = append(values, name, value)
+	}
+	for _, value := range values {
+		if strings.ContainsAny(value, "\r\n") {
+			return ErrInvalidHeader
+		}
+	}
+
+	for _, attachment := range m.Attachments {
+		if attachment.Path == "" && (attachment.Content == nil || attachment.Filename == "") {
+			return ErrInvalidAttachment
+		}
+		if strings.ContainsAny(attachment.Filename+attachment.ContentType+attachment.ContentID, "\r\n\"") {
+			return ErrInvalidHeader
+		}
+	}
+	return nil
+}
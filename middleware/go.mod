@@ -2,7 +2,16 @@ module github.com/adityak368/swissknife/middleware
 
 go 1.16
 
+replace (
+	github.com/adityak368/swissknife/crypto => ../crypto
+	github.com/adityak368/swissknife/localization => ../localization
+	github.com/adityak368/swissknife/response => ../response
+)
+
 require (
+	github.com/adityak368/swissknife/crypto v0.0.0-20201017141410-95d62b8ed51b
+	github.com/adityak368/swissknife/localization v0.0.0-20201017141410-95d62b8ed51b
+	github.com/adityak368/swissknife/response v0.0.0-20201017141410-95d62b8ed51b
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/didip/tollbooth v4.0.2+incompatible
 	github.com/labstack/echo/v4 v4.2.2
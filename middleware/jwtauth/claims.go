@@ -0,0 +1,41 @@
+package jwtauth
+
+import (
+	"strings"
+
+	"github.com/adityak368/swissknife/crypto/token"
+)
+
+// Authorizer is implemented by claims that carry scopes and roles
+type Authorizer interface {
+	HasScope(scope string) bool
+	HasRole(role string) bool
+}
+
+// Claims defines the default claims of an access token. Embed it into a struct to add custom claims
+type Claims struct {
+	token.Claims
+	// Scope is a space separated list of scopes
+	Scope string   `json:"scope,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// HasScope implements the Authorizer interface
+func (c *Claims) HasScope(scope string) bool {
+	for _, value := range strings.Fields(c.Scope) {
+		if value == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole implements the Authorizer interface
+func (c *Claims) HasRole(role string) bool {
+	for _, value := range c.Roles {
+		if value == role {
+			return true
+		}
+	}
+	return false
+}
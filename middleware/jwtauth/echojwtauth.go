@@ -0,0 +1,267 @@
+package jwtauth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/adityak368/swissknife/crypto"
+	"github.com/adityak368/swissknife/crypto/token"
+	"github.com/adityak368/swissknife/localization"
+	"github.com/adityak368/swissknife/response"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// Errors returned when a request is not authorized
+var (
+	ErrTokenMissing      = response.NewError(http.StatusUnauthorized, "TokenMissing")
+	ErrTokenInvalid      = response.NewError(http.StatusUnauthorized, "TokenInvalid")
+	ErrInsufficientScope = response.NewError(http.StatusForbidden, "InsufficientScope")
+	ErrInsufficientRole  = response.NewError(http.StatusForbidden, "InsufficientRole")
+)
+
+// ErrMissingVerifier is returned when neither a Verifier nor a KeyStore is configured
+var ErrMissingVerifier = errors.New("jwtauth requires a Verifier or a KeyStore")
+
+// claimsKey is the context key the authorizer of the verified claims is stored under for RequireScopes and RequireRoles
+const claimsKey = "_jwtauth_claims"
+
+// errorHandlerKey is the context key the error handler of JWTAuth is stored under for RequireScopes and RequireRoles
+const errorHandlerKey = "_jwtauth_error_handler"
+
+// JWTAuthConfig defines the jwt auth config
+type JWTAuthConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+	// KeyStore is used to verify tokens issued by this service if Verifier is nil
+	KeyStore *crypto.KeyStore
+	// TokenConfig is used to validate the claims if Verifier is nil. Its KeyNames are the keys tokens are verified with.
+	// Unset fields are taken from token.DefaultConfig
+	TokenConfig token.Config
+	// Verifier verifies the tokens. Ex: token.NewJWKSVerifier for tokens of other services
+	Verifier token.Verifier
+	// TokenLookup is a comma separated list of "<source>:<name>" tried in order. Source is header, cookie or query
+	TokenLookup string
+	// AuthScheme is the scheme of the authorization header
+	AuthScheme string
+	// ContextKey is the key the verified claims are stored under in the echo.Context
+	ContextKey string
+	// NewClaims returns the claims a token is decoded into. It must return a pointer
+	NewClaims func() token.RegisteredClaims
+	// Scopes are required on every request
+	Scopes []string
+	// Roles are required on every request. Any one of them is enough
+	Roles []string
+	// ErrorHandler writes the response for a rejected request
+	ErrorHandler func(c echo.Context, err *response.Error) error
+}
+
+// DefaultJWTAuthConfig defines the default jwt auth config
+var DefaultJWTAuthConfig = JWTAuthConfig{
+	Skipper:      middleware.DefaultSkipper,
+	TokenConfig:  token.DefaultConfig,
+	TokenLookup:  "header:" + echo.HeaderAuthorization,
+	AuthScheme:   "Bearer",
+	ContextKey:   "claims",
+	NewClaims:    func() token.RegisteredClaims { return &Claims{} },
+	ErrorHandler: LocalizedErrorHandler,
+}
+
+// JWTAuth returns a middleware for echo that verifies tokens with the named keys of the keystore
+func JWTAuth(store *crypto.KeyStore, keyNames ...string) echo.MiddlewareFunc {
+	config := DefaultJWTAuthConfig
+	config.KeyStore = store
+	config.TokenConfig.KeyNames = keyNames
+	return JWTAuthWithConfig(config)
+}
+
+// JWTAuthWithConfig returns a middleware for echo with config
+func JWTAuthWithConfig(config JWTAuthConfig) echo.MiddlewareFunc {
+	// Defaults
+	if config.Skipper == nil {
+		config.Skipper = DefaultJWTAuthConfig.Skipper
+	}
+	if config.TokenConfig.TTL == 0 {
+		config.TokenConfig.TTL = token.DefaultConfig.TTL
+	}
+	if config.TokenConfig.ClockSkew == 0 {
+		config.TokenConfig.ClockSkew = token.DefaultConfig.ClockSkew
+	}
+	if config.TokenConfig.Now == nil {
+		config.TokenConfig.Now = token.DefaultConfig.Now
+	}
+	if config.Verifier == nil {
+		if config.KeyStore == nil {
+			panic(ErrMissingVerifier)
+		}
+		config.Verifier = token.NewJWTWithConfig(config.KeyStore, config.TokenConfig)
+	}
+	if config.TokenLookup == "" {
+		config.TokenLookup = DefaultJWTAuthConfig.TokenLookup
+	}
+	if config.AuthScheme == "" {
+		config.AuthScheme = DefaultJWTAuthConfig.AuthScheme
+	}
+	if config.ContextKey == "" {
+		config.ContextKey = DefaultJWTAuthConfig.ContextKey
+	}
+	if config.NewClaims == nil {
+		config.NewClaims = DefaultJWTAuthConfig.NewClaims
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = DefaultJWTAuthConfig.ErrorHandler
+	}
+	extractors := tokenExtractors(config.TokenLookup, config.AuthScheme)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			var raw string
+			for _, extract := range extractors {
+				if raw = extract(c); raw != "" {
+					break
+				}
+			}
+			c.Set(errorHandlerKey, config.ErrorHandler)
+			if raw == "" {
+				return config.ErrorHandler(c, ErrTokenMissing.(*response.Error))
+			}
+
+			claims := config.NewClaims()
+			if err := config.Verifier.Verify(raw, claims); err != nil {
+				// Failures that are not response errors, Ex: a failed key set fetch, still reject the token
+				var responseError *response.Error
+				if !errors.As(err, &responseError) {
+					responseError = ErrTokenInvalid.(*response.Error)
+				}
+				return config.ErrorHandler(c, responseError)
+			}
+
+			if err := authorize(claims, config.Scopes, config.Roles); err != nil {
+				return config.ErrorHandler(c, err)
+			}
+			c.Set(config.ContextKey, claims)
+			c.Set(claimsKey, claims)
+			return next(c)
+		}
+	}
+}
+
+// RequireScopes returns a middleware for echo that requires all scopes. It must run after JWTAuth
+// and rejects requests with the ErrorHandler of JWTAuth
+func RequireScopes(scopes ...string) echo.MiddlewareFunc {
+	return requireClaims(scopes, nil)
+}
+
+// RequireRoles returns a middleware for echo that requires any one of the roles. It must run after JWTAuth
+// and rejects requests with the ErrorHandler of JWTAuth
+func RequireRoles(roles ...string) echo.MiddlewareFunc {
+	return requireClaims(nil, roles)
+}
+
+// requireClaims returns a middleware for echo that checks the claims stored by JWTAuth
+func requireClaims(scopes, roles []string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			errorHandler, ok := c.Get(errorHandlerKey).(func(c echo.Context, err *response.Error) error)
+			if !ok {
+				errorHandler = LocalizedErrorHandler
+			}
+			claims, ok := c.Get(claimsKey).(token.RegisteredClaims)
+			if !ok {
+				return errorHandler(c, ErrTokenMissing.(*response.Error))
+			}
+			if err := authorize(claims, scopes, roles); err != nil {
+				return errorHandler(c, err)
+			}
+			return next(c)
+		}
+	}
+}
+
+// ClaimsFromContext returns the default claims stored by JWTAuth
+func ClaimsFromContext(c echo.Context) (*Claims, bool) {
+	claims, ok := c.Get(claimsKey).(*Claims)
+	return claims, ok
+}
+
+// authorize checks that the claims have all scopes and any one of the roles
+func authorize(claims token.RegisteredClaims, scopes, roles []string) *response.Error {
+	if len(scopes) == 0 && len(roles) == 0 {
+		return nil
+	}
+	authorizer, ok := claims.(Authorizer)
+	if !ok {
+		return ErrInsufficientScope.(*response.Error)
+	}
+	for _, scope := range scopes {
+		if !authorizer.HasScope(scope) {
+			return ErrInsufficientScope.(*response.Error)
+		}
+	}
+	if len(roles) == 0 {
+		return nil
+	}
+	for _, role := range roles {
+		if authorizer.HasRole(role) {
+			return nil
+		}
+	}
+	return ErrInsufficientRole.(*response.Error)
+}
+
+// LocalizedErrorHandler writes the error as a response.Message translated by the translator
+// set by the localization middleware, and sets the WWW-Authenticate header
+func LocalizedErrorHandler(c echo.Context, err *response.Error) error {
+	message := err.ToMessage()
+	if translator, ok := c.Get("translator").(localization.Translator); ok {
+		message.TranslatedMessage = translator.Tr(message.MessageID, message.MessageArgs...)
+	}
+	switch err.Code {
+	case http.StatusUnauthorized:
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+	case http.StatusForbidden:
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="insufficient_scope"`)
+	}
+	return c.JSON(err.Code, message)
+}
+
+// tokenExtractors parses the token lookup into functions that read the token from the request
+func tokenExtractors(lookup, authScheme string) []func(c echo.Context) string {
+	var extractors []func(c echo.Context) string
+	for _, source := range strings.Split(lookup, ",") {
+		parts := strings.SplitN(strings.TrimSpace(source), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name := parts[1]
+		switch parts[0] {
+		case "header":
+			extractors = append(extractors, func(c echo.Context) string {
+				value := c.Request().Header.Get(name)
+				prefix := authScheme + " "
+				if len(value) > len(prefix) && strings.EqualFold(value[:len(prefix)], prefix) {
+					return strings.TrimSpace(value[len(prefix):])
+				}
+				return ""
+			})
+		case "cookie":
+			extractors = append(extractors, func(c echo.Context) string {
+				cookie, err := c.Cookie(name)
+				if err != nil {
+					return ""
+				}
+				return cookie.Value
+			})
+		case "query":
+			extractors = append(extractors, func(c echo.Context) string {
+				return c.QueryParam(name)
+			})
+		}
+	}
+	return extractors
+}
@@ -0,0 +1,108 @@
+package random
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"strings"
+
+	"github.com/adityak368/swissknife/crypto"
+)
+
+// ErrInvalidAPIKey is returned when an api key has an invalid format or checksum
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// checksumLength is the number of base62 characters of the crc32 checksum of an api key
+const checksumLength = 6
+
+// APIKeyConfig defines the api key config
+type APIKeyConfig struct {
+	// Prefix identifies the kind of key, Ex: "sk_live". It makes leaked keys recognisable by secret scanners
+	Prefix string
+	// Length is the number of random base62 characters. 32 characters are about 190 bits
+	Length int
+}
+
+// DefaultAPIKeyConfig defines the default api key config
+var DefaultAPIKeyConfig = APIKeyConfig{
+	Prefix: "sk",
+	Length: 32,
+}
+
+// NewAPIKey returns a new api key with the prefix, Ex: sk_<32 random characters><6 character checksum>
+func NewAPIKey(prefix string) (string, error) {
+	config := DefaultAPIKeyConfig
+	config.Prefix = prefix
+	return NewAPIKeyWithConfig(config)
+}
+
+// NewAPIKeyWithConfig returns a new api key with config
+func NewAPIKeyWithConfig(config APIKeyConfig) (string, error) {
+	if config.Length <= 0 {
+		config.Length = DefaultAPIKeyConfig.Length
+	}
+	body, err := String(config.Length, Base62)
+	if err != nil {
+		return "", err
+	}
+	key := body
+	if config.Prefix != "" {
+		key = config.Prefix + "_" + body
+	}
+	return key + checksum(key), nil
+}
+
+// CheckAPIKey verifies the format and the checksum of an api key without a database lookup,
+// so mistyped and made up keys are rejected early
+func CheckAPIKey(key string) error {
+	body := key[strings.LastIndex(key, "_")+1:]
+	if len(body) <= checksumLength {
+		return ErrInvalidAPIKey
+	}
+	for _, c := range body {
+		if !strings.ContainsRune(Base62, c) {
+			return ErrInvalidAPIKey
+		}
+	}
+	split := len(key) - checksumLength
+	if checksum(key[:split]) != key[split:] {
+		return ErrInvalidAPIKey
+	}
+	return nil
+}
+
+// APIKeyPrefix returns the prefix of an api key, Ex: "sk_live"
+func APIKeyPrefix(key string) string {
+	if i := strings.LastIndex(key, "_"); i >= 0 {
+		return key[:i]
+	}
+	return ""
+}
+
+// HashAPIKey returns the HMAC-SHA256 of the api key with a server side secret, hex encoded.
+// Store only the hash and look keys up by it. Api keys have enough entropy that a fast hash is safe,
+// and the secret keeps a leaked database from being used to check guessed keys
+func HashAPIKey(key string, secret []byte) string {
+	return hex.EncodeToString(crypto.HMAC([]byte(key), secret, sha256.New))
+}
+
+// VerifyAPIKey checks the api key against its stored hash in constant time
+func VerifyAPIKey(key, hash string, secret []byte) bool {
+	mac, err := hex.DecodeString(hash)
+	if err != nil {
+		return false
+	}
+	return CheckAPIKey(key) == nil && crypto.ValidMAC([]byte(key), secret, mac, sha256.New)
+}
+
+// checksum returns the crc32 of the key as fixed length base62
+func checksum(key string) string {
+	sum := crc32.ChecksumIEEE([]byte(key))
+	result := make([]byte, checksumLength)
+	for i := checksumLength - 1; i >= 0; i-- {
+		result[i] = Base62[sum%62]
+		sum /= 62
+	}
+	return string(result)
+}
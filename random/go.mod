@@ -0,0 +1,10 @@
+module github.com/adityak368/swissknife/random
+
+go 1.16
+
+replace (
+	github.com/adityak368/swissknife/crypto => ../crypto
+	github.com/adityak368/swissknife/response => ../response
+)
+
+require github.com/adityak368/swissknife/crypto v0.0.0-20201017141410-95d62b8ed51b
@@ -0,0 +1,133 @@
+package random
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidID is returned when a ULID or UUID can not be parsed
+var ErrInvalidID = errors.New("invalid id")
+
+// ulidState holds the last ULID, so ULIDs created in the same millisecond are still ordered
+var ulidState struct {
+	mu   sync.Mutex
+	last [16]byte
+}
+
+// NewULID returns a new ULID: 48 bits of unix milliseconds and 80 random bits, encoded as 26 Crockford base32 characters.
+// ULIDs sort lexicographically by creation time. Within one millisecond the random part is incremented
+func NewULID() (string, error) {
+	ulidState.mu.Lock()
+	defer ulidState.mu.Unlock()
+
+	var id [16]byte
+	putMillis(id[:6], time.Now())
+	if string(id[:6]) == string(ulidState.last[:6]) {
+		id = ulidState.last
+		if !increment(id[6:]) {
+			// The random part overflowed. Wait for the next millisecond
+			time.Sleep(time.Millisecond)
+			putMillis(id[:6], time.Now())
+			if _, err := rand.Read(id[6:]); err != nil {
+				return "", err
+			}
+		}
+	} else if _, err := rand.Read(id[6:]); err != nil {
+		return "", err
+	}
+	ulidState.last = id
+	return encodeULID(id), nil
+}
+
+// ULIDTime returns the creation time of a ULID
+func ULIDTime(id string) (time.Time, error) {
+	if len(id) != 26 || id[0] > '7' {
+		return time.Time{}, ErrInvalidID
+	}
+	var millis uint64
+	for _, c := range strings.ToUpper(id[:10]) {
+		i := strings.IndexRune(Crockford, c)
+		if i < 0 {
+			return time.Time{}, ErrInvalidID
+		}
+		millis = millis<<5 | uint64(i)
+	}
+	return time.Unix(0, int64(millis)*int64(time.Millisecond)), nil
+}
+
+// NewUUIDv7 returns a new RFC 9562 version 7 UUID: 48 bits of unix milliseconds, 12 bits of sub millisecond
+// precision and 62 random bits. UUIDv7s sort by creation time and fit uuid database columns
+func NewUUIDv7() (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[6:]); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	putMillis(id[:6], now)
+	fraction := uint16(now.Nanosecond() % int(time.Millisecond) * 4096 / int(time.Millisecond))
+	binary.BigEndian.PutUint16(id[6:8], 0x7000|fraction)
+	id[8] = id[8]&0x3f | 0x80
+
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], id[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], id[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], id[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], id[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], id[10:])
+	return string(buf), nil
+}
+
+// UUIDv7Time returns the creation time of a version 7 UUID with millisecond precision
+func UUIDv7Time(id string) (time.Time, error) {
+	// The uuid is laid out as 8-4-4-4-12 hex digits
+	if len(id) != 36 || id[8] != '-' || id[13] != '-' || id[18] != '-' || id[23] != '-' {
+		return time.Time{}, ErrInvalidID
+	}
+	raw, err := hex.DecodeString(strings.ReplaceAll(id, "-", ""))
+	if err != nil || len(raw) != 16 || raw[6]>>4 != 7 {
+		return time.Time{}, ErrInvalidID
+	}
+	var millis [8]byte
+	copy(millis[2:], raw[:6])
+	return time.Unix(0, int64(binary.BigEndian.Uint64(millis[:]))*int64(time.Millisecond)), nil
+}
+
+// putMillis writes the unix milliseconds of the time as 48 bit big endian
+func putMillis(b []byte, t time.Time) {
+	var millis [8]byte
+	binary.BigEndian.PutUint64(millis[:], uint64(t.UnixNano()/int64(time.Millisecond)))
+	copy(b, millis[2:])
+}
+
+// increment adds one to the big endian number. It returns false on overflow
+func increment(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeULID encodes the 128 bits as 26 Crockford base32 characters. The first character holds the top 3 bits
+func encodeULID(id [16]byte) string {
+	hi := binary.BigEndian.Uint64(id[:8])
+	lo := binary.BigEndian.Uint64(id[8:])
+	buf := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		buf[i] = Crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf)
+}
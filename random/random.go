@@ -0,0 +1,68 @@
+package random
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+)
+
+// Alphabets for String
+const (
+	// Base62 are the digits and the ascii letters
+	Base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// Crockford is the base32 alphabet without I, L, O and U used by ULIDs
+	Crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+)
+
+var (
+	// ErrInvalidAlphabet is returned when an alphabet has less than 2 or more than 256 characters
+	ErrInvalidAlphabet = errors.New("alphabet must have between 2 and 256 characters")
+	// ErrInvalidLength is returned when a negative length is requested
+	ErrInvalidLength = errors.New("length must not be negative")
+)
+
+// Bytes returns n cryptographically secure random bytes
+func Bytes(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, ErrInvalidLength
+	}
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Token returns a url safe token of n random bytes. Use at least 32 bytes for secrets
+func Token(n int) (string, error) {
+	b, err := Bytes(n)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// String returns a string of n characters picked uniformly from the alphabet
+func String(n int, alphabet string) (string, error) {
+	if len(alphabet) < 2 || len(alphabet) > 256 {
+		return "", ErrInvalidAlphabet
+	}
+	if n < 0 {
+		return "", ErrInvalidLength
+	}
+	// Bytes at or above limit are rejected, so every character is equally likely
+	limit := 256 - 256%len(alphabet)
+	result := make([]byte, 0, n)
+	buf := make([]byte, n)
+	for len(result) < n {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) < limit && len(result) < n {
+				result = append(result, alphabet[int(b)%len(alphabet)])
+			}
+		}
+	}
+	return string(result), nil
+}